@@ -44,8 +44,13 @@ func IsUpToDate(repository *git.Repository, lockPath string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	content, _ := ioutil.ReadFile(lockPath) //we can ignore errors here, because []byte(nil) != anyHash
-	return bytes.Equal(hash, content), nil
+	return bytes.Equal(hash, []byte(ReadLockFile(lockPath))), nil
+}
+
+//returns hash from head lock file or empty string if there is no lock yet
+func ReadLockFile(lockPath string) string {
+	content, _ := ioutil.ReadFile(lockPath) //we can ignore errors here, because missing lock means first sync
+	return string(content)
 }
 
 //creates head lock file
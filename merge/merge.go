@@ -0,0 +1,176 @@
+//Package merge implements line-based three-way merge (diff3).
+package merge
+
+import (
+	"bytes"
+)
+
+//Labels used in conflict markers
+type Labels struct {
+	Ours   string
+	Base   string
+	Theirs string
+}
+
+//Result of three-way merge
+type Result struct {
+	Content   []byte
+	Conflicts int
+}
+
+//chunk of lines between two stable points
+type chunk struct {
+	base   [][]byte
+	ours   [][]byte
+	theirs [][]byte
+}
+
+//Merges changes base->ours and base->theirs.
+//Regions changed on both sides differently are written with conflict markers
+func ThreeWay(base, ours, theirs []byte, labels Labels) Result {
+	baseLines := splitLines(base)
+	oursLines := splitLines(ours)
+	theirsLines := splitLines(theirs)
+
+	matchOurs := lcsMatches(baseLines, oursLines)
+	matchTheirs := lcsMatches(baseLines, theirsLines)
+
+	result := Result{}
+	out := &bytes.Buffer{}
+	i, a, b := 0, 0, 0
+	for i < len(baseLines) || a < len(oursLines) || b < len(theirsLines) {
+		//stable line
+		if i < len(baseLines) && matchOurs[i] == a && matchTheirs[i] == b {
+			out.Write(baseLines[i])
+			i, a, b = i+1, a+1, b+1
+			continue
+		}
+
+		//find next line which is kept on both sides
+		j := i
+		for j < len(baseLines) && (matchOurs[j] < 0 || matchTheirs[j] < 0) {
+			j++
+		}
+		nextA, nextB := len(oursLines), len(theirsLines)
+		if j < len(baseLines) {
+			nextA, nextB = matchOurs[j], matchTheirs[j]
+		}
+
+		c := chunk{
+			base:   baseLines[i:j],
+			ours:   oursLines[a:nextA],
+			theirs: theirsLines[b:nextB],
+		}
+		if !resolve(out, c) {
+			writeConflict(out, c, labels)
+			result.Conflicts++
+		}
+		i, a, b = j, nextA, nextB
+	}
+
+	result.Content = out.Bytes()
+	return result
+}
+
+//write chunk if only one side has changed or both sides are equal
+func resolve(out *bytes.Buffer, c chunk) bool {
+	switch {
+	case equalLines(c.ours, c.base):
+		writeLines(out, c.theirs)
+	case equalLines(c.theirs, c.base), equalLines(c.ours, c.theirs):
+		writeLines(out, c.ours)
+	default:
+		return false
+	}
+	return true
+}
+
+//write chunk with diff3-style conflict markers
+func writeConflict(out *bytes.Buffer, c chunk, labels Labels) {
+	writeMarker(out, "<<<<<<< ", labels.Ours)
+	writeLines(out, c.ours)
+	writeMarker(out, "||||||| ", labels.Base)
+	writeLines(out, c.base)
+	writeMarker(out, "=======", "")
+	writeLines(out, c.theirs)
+	writeMarker(out, ">>>>>>> ", labels.Theirs)
+}
+
+func writeMarker(out *bytes.Buffer, marker string, label string) {
+	out.WriteString(marker + label)
+	out.WriteByte('\n')
+}
+
+func writeLines(out *bytes.Buffer, lines [][]byte) {
+	for _, line := range lines {
+		out.Write(line)
+	}
+	//keep markers on separate lines if last line has no line break
+	if len(lines) > 0 && !bytes.HasSuffix(lines[len(lines)-1], []byte("\n")) {
+		out.WriteByte('\n')
+	}
+}
+
+//split content into lines keeping line breaks
+func splitLines(content []byte) [][]byte {
+	lines := [][]byte{}
+	for len(content) > 0 {
+		index := bytes.IndexByte(content, '\n')
+		if index < 0 {
+			lines = append(lines, content)
+			break
+		}
+		lines = append(lines, content[:index+1])
+		content = content[index+1:]
+	}
+	return lines
+}
+
+func equalLines(first [][]byte, second [][]byte) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+//returns index of matched line in second list for every line of first list or -1
+func lcsMatches(first [][]byte, second [][]byte) []int {
+	n, m := len(first), len(second)
+	lengths := make([][]int32, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if bytes.Equal(first[i], second[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, n)
+	for i := range matches {
+		matches[i] = -1
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case bytes.Equal(first[i], second[j]):
+			matches[i] = j
+			i, j = i+1, j+1
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
@@ -0,0 +1,103 @@
+package merge
+
+import (
+	"testing"
+)
+
+func TestThreeWay(t *testing.T) {
+	labels := Labels{Ours: "ours", Base: "base", Theirs: "theirs"}
+
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		expected  string
+		conflicts int
+	}{
+		{
+			name:     "no changes",
+			base:     "a\nb\nc\n",
+			ours:     "a\nb\nc\n",
+			theirs:   "a\nb\nc\n",
+			expected: "a\nb\nc\n",
+		},
+		{
+			name:     "only ours changed",
+			base:     "a\nb\nc\n",
+			ours:     "a\nB\nc\n",
+			theirs:   "a\nb\nc\n",
+			expected: "a\nB\nc\n",
+		},
+		{
+			name:     "only theirs changed",
+			base:     "a\nb\nc\n",
+			ours:     "a\nb\nc\n",
+			theirs:   "a\nb\nC\n",
+			expected: "a\nb\nC\n",
+		},
+		{
+			name:     "different regions changed",
+			base:     "a\nb\nc\nd\ne\n",
+			ours:     "A\nb\nc\nd\ne\n",
+			theirs:   "a\nb\nc\nd\nE\n",
+			expected: "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:     "same change on both sides",
+			base:     "a\nb\nc\n",
+			ours:     "a\nB\nc\n",
+			theirs:   "a\nB\nc\n",
+			expected: "a\nB\nc\n",
+		},
+		{
+			name:     "insertions and deletions",
+			base:     "a\nb\nc\nd\n",
+			ours:     "a\nx\nb\nc\nd\n",
+			theirs:   "a\nb\nd\n",
+			expected: "a\nx\nb\nd\n",
+		},
+		{
+			name:     "lines appended on one side",
+			base:     "a\n",
+			ours:     "a\n",
+			theirs:   "a\nb\nc\n",
+			expected: "a\nb\nc\n",
+		},
+		{
+			name:      "conflicting changes",
+			base:      "a\nb\nc\n",
+			ours:      "a\nB1\nc\n",
+			theirs:    "a\nB2\nc\n",
+			expected:  "a\n<<<<<<< ours\nB1\n||||||| base\nb\n=======\nB2\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "conflict at the end without line break",
+			base:      "a\nb",
+			ours:      "a\nB1",
+			theirs:    "a\nB2",
+			expected:  "a\n<<<<<<< ours\nB1\n||||||| base\nb\n=======\nB2\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+		{
+			name:     "empty base",
+			base:     "",
+			ours:     "",
+			theirs:   "a\n",
+			expected: "a\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := ThreeWay([]byte(test.base), []byte(test.ours), []byte(test.theirs), labels)
+			if string(result.Content) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, string(result.Content))
+			}
+			if result.Conflicts != test.conflicts {
+				t.Errorf("expected %d conflicts, got %d", test.conflicts, result.Conflicts)
+			}
+		})
+	}
+}
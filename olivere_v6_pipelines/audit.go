@@ -0,0 +1,239 @@
+package olivere_v6_pipelines
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/konovenschi/aggretastic-sync/errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"gopkg.in/src-d/go-billy.v4"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+//identifier which has been qualified with upstream package name by type solver
+type qualification struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Identifier string `json:"identifier"`
+	Object     string `json:"object"`
+	Kind       string `json:"kind"`
+}
+
+//creates qualification record for identifier resolved to upstream object
+func newQualification(file string, line int, object types.Object) qualification {
+	kind := "var"
+	switch object.(type) {
+	case *types.Func:
+		kind = "func"
+	case *types.TypeName:
+		kind = "type"
+	case *types.Const:
+		kind = "const"
+	}
+	return qualification{
+		File:       file,
+		Line:       line,
+		Identifier: object.Name(),
+		Object:     object.Pkg().Path() + "." + object.Name(),
+		Kind:       kind,
+	}
+}
+
+//lines are not compared, they shift on every upstream change
+func (q qualification) key() string {
+	return q.File + " " + q.Object
+}
+
+//takes lines of qualifications from saved files. Lines recorded during correction shift when imports
+//are added or declarations are removed, so k-th qualification of identifier in file gets line of
+//k-th qualified selector with that identifier. Qualifications which are not in file anymore are dropped
+func locateQualifications(fs billy.Filesystem, buildPath string, packageName string, qualifications []qualification) []qualification {
+	byFile := map[string][]qualification{}
+	for _, item := range qualifications {
+		byFile[item.File] = append(byFile[item.File], item)
+	}
+
+	located := []qualification{}
+	for file, items := range byFile {
+		lines := qualifiedLines(fs, buildPath+file, packageName)
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Line < items[j].Line
+		})
+		found := map[string]int{}
+		for _, item := range items {
+			index := found[item.Identifier]
+			if index >= len(lines[item.Identifier]) {
+				continue
+			}
+			found[item.Identifier]++
+			item.Line = lines[item.Identifier][index]
+			located = append(located, item)
+		}
+	}
+	return located
+}
+
+//returns lines of selectors qualified with package name keyed by identifier, in source order
+func qualifiedLines(fs billy.Filesystem, filename string, packageName string) map[string][]int {
+	lines := map[string][]int{}
+	file, err := fs.Open(filename)
+	if err != nil {
+		return lines
+	}
+	defer func() {
+		err := file.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}()
+	content, err := ioutil.ReadAll(file)
+	errors.PanicOnError(errCantOpenFile, err)
+
+	fileSet := token.NewFileSet()
+	parsed, err := parser.ParseFile(fileSet, filename, content, 0)
+	if err != nil {
+		return lines
+	}
+	ast.Inspect(parsed, func(node ast.Node) bool {
+		selector, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := selector.X.(*ast.Ident); ok && ident.Name == packageName {
+			lines[selector.Sel.Name] = append(lines[selector.Sel.Name], fileSet.Position(selector.Pos()).Line)
+		}
+		return true
+	})
+	return lines
+}
+
+//content of JSON report
+type qualificationReport struct {
+	Commit         string          `json:"commit"`
+	Qualifications []qualification `json:"qualifications"`
+}
+
+//writes JSON and Markdown reports of qualified identifiers. Report of previous sync
+//is compared with the new one, so new upstream dependencies are visible in review
+type auditPipeline struct {
+	ReportPath     string
+	Commit         string
+	Qualifications []qualification
+	previous       *qualificationReport
+	added          []qualification
+	removed        []qualification
+}
+
+//run audit pipeline
+func (ap *auditPipeline) Run() {
+	sort.Slice(ap.Qualifications, func(i, j int) bool {
+		if ap.Qualifications[i].File != ap.Qualifications[j].File {
+			return ap.Qualifications[i].File < ap.Qualifications[j].File
+		}
+		return ap.Qualifications[i].Line < ap.Qualifications[j].Line
+	})
+
+	ap.readPrevious()
+	ap.diff()
+	ap.saveJSON()
+	ap.saveMarkdown()
+	ap.report()
+}
+
+//read JSON report of previous sync if it exists
+func (ap *auditPipeline) readPrevious() {
+	content, err := ioutil.ReadFile(ap.ReportPath + ".json")
+	if err != nil {
+		return
+	}
+	previous := &qualificationReport{}
+	if err := json.Unmarshal(content, previous); err != nil {
+		fmt.Println("Previous qualification report is broken and will be replaced: " + err.Error())
+		return
+	}
+	ap.previous = previous
+}
+
+//find qualifications which appeared or disappeared since previous sync
+func (ap *auditPipeline) diff() {
+	if ap.previous == nil {
+		return
+	}
+	ap.added = subtractQualifications(ap.Qualifications, ap.previous.Qualifications)
+	ap.removed = subtractQualifications(ap.previous.Qualifications, ap.Qualifications)
+}
+
+//returns qualifications from list which are not present in other list
+func subtractQualifications(list []qualification, other []qualification) []qualification {
+	keys := map[string]bool{}
+	for _, item := range other {
+		keys[item.key()] = true
+	}
+	result := []qualification{}
+	for _, item := range list {
+		if !keys[item.key()] {
+			keys[item.key()] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (ap *auditPipeline) saveJSON() {
+	content, err := json.MarshalIndent(qualificationReport{
+		Commit:         ap.Commit,
+		Qualifications: ap.Qualifications,
+	}, "", "  ")
+	errors.PanicOnError(errCantWriteFile, err)
+
+	err = ioutil.WriteFile(ap.ReportPath+".json", append(content, '\n'), os.ModePerm)
+	errors.PanicOnError(errCantWriteFile, err)
+}
+
+func (ap *auditPipeline) saveMarkdown() {
+	buffer := &bytes.Buffer{}
+	buffer.WriteString("# Qualified identifiers\n\n")
+	buffer.WriteString(fmt.Sprintf("Identifiers qualified with upstream package by type solver, olivere/elastic@%s.\n", ap.Commit))
+
+	if ap.previous != nil {
+		buffer.WriteString(fmt.Sprintf("\n## Changes since olivere/elastic@%s\n", ap.previous.Commit))
+		writeQualificationsTable(buffer, "### Added", ap.added)
+		writeQualificationsTable(buffer, "### Removed", ap.removed)
+	}
+	writeQualificationsTable(buffer, "## All qualifications", ap.Qualifications)
+
+	err := ioutil.WriteFile(ap.ReportPath+".md", buffer.Bytes(), os.ModePerm)
+	errors.PanicOnError(errCantWriteFile, err)
+}
+
+func writeQualificationsTable(buffer *bytes.Buffer, heading string, qualifications []qualification) {
+	buffer.WriteString(fmt.Sprintf("\n%s (%d)\n\n", heading, len(qualifications)))
+	if len(qualifications) == 0 {
+		return
+	}
+	buffer.WriteString("| File | Line | Identifier | Object | Kind |\n")
+	buffer.WriteString("|---|---|---|---|---|\n")
+	for _, item := range qualifications {
+		buffer.WriteString(fmt.Sprintf("| %s | %d | `%s` | `%s` | %s |\n", item.File, item.Line, item.Identifier, item.Object, item.Kind))
+	}
+}
+
+//print qualifications which changed since previous sync
+func (ap *auditPipeline) report() {
+	fmt.Printf("Qualified identifiers: %d (report: %s.md)\n", len(ap.Qualifications), ap.ReportPath)
+	if len(ap.added) > 0 {
+		fmt.Println("New upstream dependencies:")
+		for _, item := range ap.added {
+			fmt.Printf("\t%s:%d: %s (%s)\n", item.File, item.Line, item.Object, item.Kind)
+		}
+	}
+	if len(ap.removed) > 0 {
+		fmt.Println("Upstream dependencies which are not used anymore:")
+		for _, item := range ap.removed {
+			fmt.Printf("\t%s: %s (%s)\n", item.File, item.Object, item.Kind)
+		}
+	}
+}
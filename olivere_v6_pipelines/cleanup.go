@@ -0,0 +1,277 @@
+package olivere_v6_pipelines
+
+import (
+	"fmt"
+	"github.com/dave/dst/decorator"
+	"github.com/konovenschi/aggretastic-sync/errors"
+	"github.com/konovenschi/aggretastic-sync/pretty_dst"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"gopkg.in/src-d/go-billy.v4"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//removal of declaration can make other declarations unused, cleanup is repeated at most this number of times
+const cleanupMaxPasses = 10
+
+//type errors printed in report when dead declarations removal is skipped
+const cleanupMaxReportedErrors = 10
+
+//removes unused imports and unreferenced unexported functions and types from generated files.
+//References are resolved with type information of the whole build package (including tests)
+type cleanupPipeline struct {
+	BuildPath      string
+	PackageName    string
+	ProtectedFiles []string
+	Importer       types.Importer
+	FS             billy.Filesystem
+	pkg            *pretty_dst.Package
+	restorer       *decorator.Restorer
+	info           *types.Info
+	errs           []error
+	removed        []string
+	exhausted      bool
+}
+
+//unexported declaration with nodes which belong to it (type declaration and its methods)
+type deadCandidate struct {
+	object types.Object
+	nodes  []ast.Node
+}
+
+//run cleanup pipeline. Removal of declaration can make other declarations unused, so it is repeated
+func (cp *cleanupPipeline) Run() {
+	cp.pkg = pretty_dst.NewPackage(cp.FS, cp.BuildPath)
+	err := cp.pkg.Load()
+	errors.PanicOnError(errCantReadDir, err)
+
+	pass := 0
+	for ; pass < cleanupMaxPasses && cp.clean(); pass++ {
+	}
+	cp.exhausted = pass == cleanupMaxPasses
+	err = cp.pkg.Save()
+	errors.PanicOnError(errCantWriteFile, err)
+	cp.report()
+}
+
+//type check package and remove everything which is not referenced. Returns true if anything was removed
+func (cp *cleanupPipeline) clean() bool {
+	files := cp.check()
+	removed := false
+	for _, filename := range cp.pkg.Filenames() {
+		if cp.isProtected(filename) {
+			continue
+		}
+		file := files[filename]
+		for _, imp := range cp.unusedImports(file) {
+			removed = cp.remove(imp, "import "+imp.Path.Value) || removed
+		}
+		//uses in broken expressions can be missing, so declarations are kept if package has errors
+		if len(cp.errs) > 0 {
+			continue
+		}
+		for _, candidate := range cp.deadDeclarations(file, files) {
+			for _, node := range candidate.nodes {
+				description := candidate.object.Name()
+				if method, ok := node.(*ast.FuncDecl); ok && method.Recv != nil {
+					description += "." + method.Name.Name
+				}
+				removed = cp.remove(node, description) || removed
+			}
+		}
+	}
+	return removed
+}
+
+//restore ast of all sources into single fileset and collect type information. Errors are tolerated
+func (cp *cleanupPipeline) check() map[string]*ast.File {
+	restorer, files := cp.pkg.Restore()
+	cp.restorer = restorer
+	cp.info = &types.Info{
+		Defs:      make(map[*ast.Ident]types.Object),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Implicits: make(map[ast.Node]types.Object),
+	}
+
+	astSet := []*ast.File{}
+	for _, filename := range cp.pkg.Filenames() {
+		astSet = append(astSet, files[filename])
+	}
+
+	cp.errs = nil
+	config := types.Config{
+		Importer: cp.Importer,
+		Error: func(err error) {
+			cp.errs = append(cp.errs, err)
+		},
+	}
+	_, _ = config.Check(cp.PackageName, cp.restorer.Fset, astSet, cp.info)
+	return files
+}
+
+//returns imports which are not referenced by any identifier of file
+func (cp *cleanupPipeline) unusedImports(file *ast.File) []*ast.ImportSpec {
+	used := map[types.Object]bool{}
+	for _, object := range cp.info.Uses {
+		if _, ok := object.(*types.PkgName); ok {
+			used[object] = true
+		}
+	}
+
+	unused := []*ast.ImportSpec{}
+	for _, imp := range importSpecs(file) {
+		object := cp.info.Implicits[imp]
+		if imp.Name != nil {
+			if imp.Name.Name == "_" || imp.Name.Name == "." {
+				continue
+			}
+			object = cp.info.Defs[imp.Name]
+		}
+		if object != nil && !used[object] {
+			unused = append(unused, imp)
+		}
+	}
+	return unused
+}
+
+//restored ast doesn't contain list of imports, so they are collected from declarations
+func importSpecs(file *ast.File) []*ast.ImportSpec {
+	imports := []*ast.ImportSpec{}
+	for _, decl := range file.Decls {
+		if declaration, ok := decl.(*ast.GenDecl); ok && declaration.Tok == token.IMPORT {
+			for _, spec := range declaration.Specs {
+				imports = append(imports, spec.(*ast.ImportSpec))
+			}
+		}
+	}
+	return imports
+}
+
+//returns unexported functions and types of file which are referenced only from their own declarations
+func (cp *cleanupPipeline) deadDeclarations(file *ast.File, files map[string]*ast.File) []*deadCandidate {
+	candidates := map[types.Object]*deadCandidate{}
+	for _, decl := range file.Decls {
+		switch declaration := decl.(type) {
+		case *ast.FuncDecl:
+			name := declaration.Name.Name
+			if declaration.Recv == nil && !ast.IsExported(name) && name != "init" && name != "_" {
+				cp.addCandidate(candidates, declaration.Name, declaration)
+			}
+		case *ast.GenDecl:
+			for _, spec := range declaration.Specs {
+				if typeSpec, ok := spec.(*ast.TypeSpec); ok && !ast.IsExported(typeSpec.Name.Name) && typeSpec.Name.Name != "_" {
+					cp.addCandidate(candidates, typeSpec.Name, typeSpec)
+				}
+			}
+		}
+	}
+
+	//methods are removed together with their type
+	for _, other := range files {
+		for _, decl := range other.Decls {
+			function, ok := decl.(*ast.FuncDecl)
+			if !ok || function.Recv == nil || len(function.Recv.List) == 0 {
+				continue
+			}
+			if candidate, ok := candidates[cp.info.Uses[receiverIdent(function)]]; ok {
+				candidate.nodes = append(candidate.nodes, function)
+			}
+		}
+	}
+
+	for ident, object := range cp.info.Uses {
+		candidate, ok := candidates[object]
+		if ok && !candidate.contains(ident) {
+			delete(candidates, object)
+		}
+	}
+
+	dead := []*deadCandidate{}
+	for _, candidate := range candidates {
+		dead = append(dead, candidate)
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		return dead[i].object.Name() < dead[j].object.Name()
+	})
+	return dead
+}
+
+func (cp *cleanupPipeline) addCandidate(candidates map[types.Object]*deadCandidate, name *ast.Ident, node ast.Node) {
+	if object := cp.info.Defs[name]; object != nil {
+		candidates[object] = &deadCandidate{object: object, nodes: []ast.Node{node}}
+	}
+}
+
+//checks if identifier is inside of declaration nodes
+func (dc *deadCandidate) contains(ident *ast.Ident) bool {
+	for _, node := range dc.nodes {
+		if node.Pos() <= ident.Pos() && ident.End() <= node.End() {
+			return true
+		}
+	}
+	return false
+}
+
+//returns type name identifier of method receiver
+func receiverIdent(function *ast.FuncDecl) *ast.Ident {
+	receiver := function.Recv.List[0].Type
+	if star, ok := receiver.(*ast.StarExpr); ok {
+		receiver = star.X
+	}
+	ident, _ := receiver.(*ast.Ident)
+	return ident
+}
+
+//remove dst node restored to ast node. Methods can be declared in other file than their type
+func (cp *cleanupPipeline) remove(node ast.Node, description string) bool {
+	filename := cp.restorer.Fset.Position(node.Pos()).Filename
+	if cp.isProtected(filename) {
+		return false
+	}
+	target, ok := cp.restorer.Dst.Nodes[node]
+	if !ok || !cp.pkg.File(filename).RemoveDeclaration(target) {
+		return false
+	}
+	cp.removed = append(cp.removed, fmt.Sprintf("%s: %s", filepath.Base(filename), description))
+	return true
+}
+
+//Aggretastic package files are written by hand and upstream tests are kept as is, so they are never changed
+func (cp *cleanupPipeline) isProtected(filename string) bool {
+	if strings.HasSuffix(filename, "_test.go") {
+		return true
+	}
+	for _, protected := range cp.ProtectedFiles {
+		if filepath.Base(filename) == filepath.Base(protected) {
+			return true
+		}
+	}
+	return false
+}
+
+//print removed imports and declarations
+func (cp *cleanupPipeline) report() {
+	if len(cp.errs) > 0 {
+		fmt.Printf("Build package has type errors (%d), unused declarations are not removed:\n", len(cp.errs))
+		for i, err := range cp.errs {
+			if i == cleanupMaxReportedErrors {
+				fmt.Printf("\t... and %d more\n", len(cp.errs)-i)
+				break
+			}
+			fmt.Println("\t" + err.Error())
+		}
+	}
+	if cp.exhausted {
+		fmt.Printf("Cleanup stopped after %d passes, some unused declarations can be left\n", cleanupMaxPasses)
+	}
+	if len(cp.removed) == 0 {
+		return
+	}
+	fmt.Printf("Unused imports and declarations removed (%d):\n", len(cp.removed))
+	for _, removed := range cp.removed {
+		fmt.Println("\t" + removed)
+	}
+}
@@ -0,0 +1,138 @@
+package olivere_v6_pipelines
+
+import (
+	"fmt"
+	"github.com/dave/jennifer/jen"
+	"github.com/konovenschi/aggretastic-sync/errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"gopkg.in/src-d/go-billy.v4"
+	"sort"
+	"strings"
+)
+
+//checks that every enriched aggregation implements Aggretastic interface
+//and generates compile-time assertions for them
+type conformancePipeline struct {
+	BuildPath     string
+	Filename      string
+	PackageName   string
+	InterfaceName string
+	Commit        string
+	FS            billy.Filesystem
+	Importer      types.Importer
+
+	//aggregation types enriched by file updater with their strategies
+	Enriched   map[string]string
+	conforming []string
+	failures   []string
+}
+
+//run conformance pipeline
+func (cp *conformancePipeline) Run() {
+	pkg := cp.check()
+
+	iface := cp.lookupInterface(pkg)
+	names := []string{}
+	for name := range cp.Enriched {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typeName, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			cp.failures = append(cp.failures, name+": not declared in build package")
+			continue
+		}
+		cp.verify(typeName, iface)
+	}
+
+	if len(cp.failures) > 0 {
+		errors.PanicOnError(errNonConforming, fmt.Errorf("\n\t%s", strings.Join(cp.failures, "\n\t")))
+	}
+	cp.saveFile()
+}
+
+//type check build package without tests
+func (cp *conformancePipeline) check() *types.Package {
+	fileSet := token.NewFileSet()
+	files := []*ast.File{}
+
+	list, err := cp.FS.ReadDir(cp.BuildPath)
+	errors.PanicOnError(errCantReadDir, err)
+	for _, file := range list {
+		name := file.Name()
+		if file.IsDir() || name == cp.Filename || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		source, err := cp.FS.Open(cp.BuildPath + name)
+		errors.PanicOnError(errCantOpenFile, err)
+
+		astFile, err := parser.ParseFile(fileSet, cp.BuildPath+name, source, parser.ParseComments)
+		errors.PanicOnError(errCantParseFile, err)
+		files = append(files, astFile)
+
+		err = source.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}
+
+	config := types.Config{Importer: cp.Importer}
+	pkg, err := config.Check(cp.PackageName, fileSet, files, nil)
+	errors.PanicOnError(errTypeCheck, err)
+	return pkg
+}
+
+//find Aggretastic aggregation interface in package
+func (cp *conformancePipeline) lookupInterface(pkg *types.Package) *types.Interface {
+	object, ok := pkg.Scope().Lookup(cp.InterfaceName).(*types.TypeName)
+	if !ok {
+		panic(errNonConforming.Error() + "interface " + cp.InterfaceName + " is not declared")
+	}
+	iface, ok := object.Type().Underlying().(*types.Interface)
+	if !ok {
+		panic(errNonConforming.Error() + cp.InterfaceName + " is not an interface")
+	}
+	return iface
+}
+
+//check that pointer to type implements interface
+func (cp *conformancePipeline) verify(typeName *types.TypeName, iface *types.Interface) {
+	pointer := types.NewPointer(typeName.Type())
+	if types.Implements(pointer, iface) {
+		cp.conforming = append(cp.conforming, typeName.Name())
+		return
+	}
+
+	method, wrongType := types.MissingMethod(pointer, iface, true)
+	reason := "missing method " + method.Name()
+	if wrongType {
+		reason = "wrong signature of method " + method.Name()
+	}
+	cp.failures = append(cp.failures, fmt.Sprintf("%s: %s", typeName.Name(), reason))
+}
+
+//render assertions file into build path
+func (cp *conformancePipeline) saveFile() {
+	sort.Strings(cp.conforming)
+
+	file := jen.NewFile(cp.PackageName)
+	file.HeaderComment(fmt.Sprintf(generatedPackageHeader, cp.Commit))
+	file.Comment("Compile-time checks that every aggregation implements " + cp.InterfaceName)
+	file.Var().DefsFunc(func(group *jen.Group) {
+		for _, name := range cp.conforming {
+			group.Id("_").Id(cp.InterfaceName).Op("=").Parens(jen.Op("*").Id(name)).Call(jen.Nil())
+		}
+	})
+
+	output, err := cp.FS.Create(cp.BuildPath + cp.Filename)
+	errors.PanicOnError(errCantOpenFile, err)
+	defer func() {
+		err := output.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}()
+
+	err = file.Render(output)
+	errors.PanicOnError(errCantWriteFile, err)
+}
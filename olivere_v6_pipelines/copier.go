@@ -0,0 +1,414 @@
+package olivere_v6_pipelines
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dlclark/regexp2"
+	"github.com/konovenschi/aggretastic-sync/errors"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"gopkg.in/src-d/go-billy.v4"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//copies upstream declarations which can't be referenced from Aggretastic (unexported helpers,
+//types with methods) into generated file together with unexported declarations they depend on.
+//References to exported upstream declarations are qualified with upstream package name
+type declarationCopier struct {
+	Importer    *sourceImporter
+	PackagePath string
+	PackageName string
+	BuildPath   string
+	Filename    string
+	Header      string
+	FS          billy.Filesystem
+
+	copied         map[string]bool
+	sources        map[string]string
+	imports        map[string]string
+	keys           []string
+	qualifications map[string][]qualification
+	methods        map[string]string
+	dropped        map[string]bool
+}
+
+//single declaration source prepared for copying. Prefix contains doc comment
+//and keyword for specs of grouped declarations
+type copiedDeclaration struct {
+	node   ast.Node
+	file   string
+	names  []string
+	prefix string
+}
+
+//copy upstream declaration with its unexported dependencies. Returns false if there is no such declaration
+func (dc *declarationCopier) copy(name string) bool {
+	if dc.copied == nil {
+		dc.copied = map[string]bool{}
+		dc.sources = map[string]string{}
+		dc.imports = map[string]string{}
+		dc.qualifications = map[string][]qualification{}
+		dc.methods = map[string]string{}
+		dc.dropped = map[string]bool{}
+	}
+	if dc.copied[name] {
+		return true
+	}
+
+	source := dc.Importer.source(dc.PackagePath)
+	pkg, _ := dc.Importer.Import(dc.PackagePath)
+	if source == nil || pkg == nil {
+		return false
+	}
+	object := pkg.Scope().Lookup(name)
+	if object == nil {
+		return false
+	}
+	declaration := dc.findDeclaration(source, object.Pos())
+	if declaration == nil {
+		return false
+	}
+
+	for _, declared := range declaration.names {
+		dc.copied[declared] = true
+	}
+	dc.add(source, pkg, declaration)
+
+	//methods are required by copied code, but can't be declared on upstream types
+	if _, isType := object.(*types.TypeName); isType {
+		for _, method := range dc.findMethods(source, name) {
+			methodName := name + "." + method.node.(*ast.FuncDecl).Name.Name
+			if !dc.dropped[methodName] {
+				dc.methods[methodName] = dc.add(source, pkg, method)
+			}
+		}
+	}
+	return true
+}
+
+//copy transitive closure of unexported upstream declarations referenced from files
+//matching patterns. Returns number of copied declarations
+func (dc *declarationCopier) copyReferenced(patterns []string) int {
+	source := dc.Importer.source(dc.PackagePath)
+	pkg, _ := dc.Importer.Import(dc.PackagePath)
+	if source == nil || pkg == nil {
+		return 0
+	}
+
+	matchers := []*regexp2.Regexp{}
+	for _, pattern := range patterns {
+		matchers = append(matchers, regexp2.MustCompile(pattern, 0))
+	}
+
+	copied := len(dc.copied)
+	for _, file := range source.Files {
+		filename := dc.Importer.fileSet.Position(file.Pos()).Filename
+		//excluded files are not extracted, so they don't need helpers
+		if !matchesAny(matchers, filepath.Base(filename)) || !dc.isInBuild(filename) {
+			continue
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			ident, ok := node.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			object := source.Info.Uses[ident]
+			if object == nil || object.Exported() || object.Pkg() != pkg || object.Parent() != pkg.Scope() {
+				return true
+			}
+			if !dc.isInBuild(dc.Importer.fileSet.Position(object.Pos()).Filename) {
+				dc.copy(object.Name())
+			}
+			return true
+		})
+	}
+	return len(dc.copied) - copied
+}
+
+//checks if file name matches any of patterns
+func matchesAny(matchers []*regexp2.Regexp, filename string) bool {
+	for _, matcher := range matchers {
+		if match, _ := matcher.MatchString(filename); match {
+			return true
+		}
+	}
+	return false
+}
+
+//returns names of declarations copied so far
+func (dc *declarationCopier) copiedNames() []string {
+	names := []string{}
+	for name := range dc.copied {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//rewrite declaration references and keep result. Returns key of declaration
+func (dc *declarationCopier) add(source *packageSource, pkg *types.Package, declaration *copiedDeclaration) string {
+	content := source.Contents[declaration.file]
+	fileSet := dc.Importer.fileSet
+	start := fileSet.Position(declaration.node.Pos()).Offset
+	end := fileSet.Position(declaration.node.End()).Offset
+
+	//insert qualifiers from the end, so offsets stay valid
+	qualifiers := []int{}
+	qualifications := []qualification{}
+	dependencies := []string{}
+	ast.Inspect(declaration.node, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		object := source.Info.Uses[ident]
+		if object == nil {
+			return true
+		}
+		if imported, ok := object.(*types.PkgName); ok {
+			dc.addImport(imported.Imported().Path(), imported.Name())
+			return true
+		}
+		if object.Pkg() != pkg || object.Parent() != pkg.Scope() || dc.isInBuild(fileSet.Position(object.Pos()).Filename) {
+			return true
+		}
+		if object.Exported() && !dc.copied[object.Name()] {
+			qualifiers = append(qualifiers, fileSet.Position(ident.Pos()).Offset-start)
+			//line is taken from saved file later
+			qualifications = append(qualifications, newQualification(dc.Filename, 0, object))
+			dc.addImport(dc.PackagePath, dc.PackageName)
+		} else {
+			dependencies = append(dependencies, object.Name())
+		}
+		return true
+	})
+
+	text := content[start:end]
+	sort.Sort(sort.Reverse(sort.IntSlice(qualifiers)))
+	for _, offset := range qualifiers {
+		text = append(text[:offset:offset], append([]byte(dc.PackageName+"."), text[offset:]...)...)
+	}
+
+	key := fmt.Sprintf("%s:%d", declaration.file, start)
+	if _, exists := dc.sources[key]; !exists {
+		dc.keys = append(dc.keys, key)
+	}
+	dc.sources[key] = declaration.prefix + string(text)
+	dc.qualifications[key] = qualifications
+
+	for _, dependency := range dependencies {
+		dc.copy(dependency)
+	}
+	return key
+}
+
+//remove copied method which is declared in build package too ("T.Method").
+//Method is not copied again. Returns false if method has not been copied
+func (dc *declarationCopier) drop(method string) bool {
+	key, ok := dc.methods[method]
+	if !ok {
+		return false
+	}
+	delete(dc.methods, method)
+	dc.dropped[method] = true
+	delete(dc.sources, key)
+	delete(dc.qualifications, key)
+	for i, other := range dc.keys {
+		if other == key {
+			dc.keys = append(dc.keys[:i], dc.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+//returns references qualified in copied declarations
+func (dc *declarationCopier) qualified() []qualification {
+	qualifications := []qualification{}
+	for _, key := range dc.keys {
+		qualifications = append(qualifications, dc.qualifications[key]...)
+	}
+	return qualifications
+}
+
+func (dc *declarationCopier) addImport(importPath string, name string) {
+	if name == path.Base(importPath) {
+		name = ""
+	}
+	dc.imports[importPath] = name
+}
+
+//checks if upstream file has been extracted to build path, so its declarations are available
+func (dc *declarationCopier) isInBuild(filename string) bool {
+	name := strings.Replace(filepath.Base(filename), "search_aggs_", "aggs_", -1)
+	_, err := dc.FS.Stat(dc.BuildPath + name)
+	return err == nil
+}
+
+//find top-level declaration of object at position. Grouped const declarations
+//are copied completely, because iota values depend on the group
+func (dc *declarationCopier) findDeclaration(source *packageSource, position token.Pos) *copiedDeclaration {
+	for _, file := range source.Files {
+		if position < file.Pos() || position > file.End() {
+			continue
+		}
+		filename := dc.Importer.fileSet.Position(file.Pos()).Filename
+		for _, decl := range file.Decls {
+			switch declaration := decl.(type) {
+			case *ast.FuncDecl:
+				if declaration.Recv == nil && declaration.Name.Pos() == position {
+					return &copiedDeclaration{
+						node:   declaration,
+						file:   filename,
+						names:  []string{declaration.Name.Name},
+						prefix: dc.docText(source, filename, declaration.Doc),
+					}
+				}
+			case *ast.GenDecl:
+				if found := findSpec(declaration, position); found != nil {
+					if declaration.Lparen.IsValid() && declaration.Tok != token.CONST {
+						return &copiedDeclaration{
+							node:   found,
+							file:   filename,
+							names:  specNames(found),
+							prefix: dc.docText(source, filename, specDoc(found)) + declaration.Tok.String() + " ",
+						}
+					}
+					names := []string{}
+					for _, spec := range declaration.Specs {
+						names = append(names, specNames(spec)...)
+					}
+					return &copiedDeclaration{
+						node:   declaration,
+						file:   filename,
+						names:  names,
+						prefix: dc.docText(source, filename, declaration.Doc),
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//find methods declared for type in upstream files
+func (dc *declarationCopier) findMethods(source *packageSource, typeName string) []*copiedDeclaration {
+	methods := []*copiedDeclaration{}
+	for _, file := range source.Files {
+		filename := dc.Importer.fileSet.Position(file.Pos()).Filename
+		for _, decl := range file.Decls {
+			function, ok := decl.(*ast.FuncDecl)
+			if !ok || function.Recv == nil || len(function.Recv.List) == 0 {
+				continue
+			}
+			receiver := function.Recv.List[0].Type
+			if star, ok := receiver.(*ast.StarExpr); ok {
+				receiver = star.X
+			}
+			if ident, ok := receiver.(*ast.Ident); ok && ident.Name == typeName {
+				methods = append(methods, &copiedDeclaration{
+					node:   function,
+					file:   filename,
+					prefix: dc.docText(source, filename, function.Doc),
+				})
+			}
+		}
+	}
+	return methods
+}
+
+//returns spec which declares name at position
+func findSpec(declaration *ast.GenDecl, position token.Pos) ast.Spec {
+	for _, spec := range declaration.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			if s.Name.Pos() == position {
+				return s
+			}
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				if name.Pos() == position {
+					return s
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func specNames(spec ast.Spec) []string {
+	names := []string{}
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		names = append(names, s.Name.Name)
+	case *ast.ValueSpec:
+		for _, name := range s.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+//returns source of doc comment followed by line break or empty string
+func (dc *declarationCopier) docText(source *packageSource, filename string, doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	start := dc.Importer.fileSet.Position(doc.Pos()).Offset
+	end := dc.Importer.fileSet.Position(doc.End()).Offset
+	return string(source.Contents[filename][start:end]) + "\n"
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+//render copied declarations into build path
+func (dc *declarationCopier) save() {
+	buffer := &bytes.Buffer{}
+	buffer.WriteString(dc.Header + "\n\n")
+	buffer.WriteString("package " + aggretasticPackageName + "\n\n")
+
+	paths := []string{}
+	for importPath := range dc.imports {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+	if len(paths) > 0 {
+		buffer.WriteString("import (\n")
+		for _, importPath := range paths {
+			buffer.WriteString(fmt.Sprintf("\t%s %q\n", dc.imports[importPath], importPath))
+		}
+		buffer.WriteString(")\n\n")
+	}
+
+	for _, key := range dc.keys {
+		buffer.WriteString(dc.sources[key] + "\n\n")
+	}
+
+	content, err := format.Source(buffer.Bytes())
+	if err != nil {
+		content = buffer.Bytes()
+	}
+
+	file, err := dc.FS.Create(dc.BuildPath + dc.Filename)
+	errors.PanicOnError(errCantOpenFile, err)
+	defer func() {
+		err := file.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}()
+
+	_, err = file.Write(content)
+	errors.PanicOnError(errCantWriteFile, err)
+}
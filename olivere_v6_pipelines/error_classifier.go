@@ -0,0 +1,147 @@
+package olivere_v6_pipelines
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+)
+
+//fixers which can be applied to type errors
+const (
+	fixQualify         = "qualify"
+	fixCopyDeclaration = "copy declaration"
+	fixRemoveDuplicate = "remove duplicate"
+	fixDropImport      = "drop import"
+	fixDropMethod      = "drop copied method"
+)
+
+//type error with fixer which should handle it
+type classifiedError struct {
+	types.Error
+	Fix  string
+	Name string
+}
+
+//picks fixer for type errors of build package
+type errorClassifier struct {
+	Upstream       *types.Package
+	ProtectedFiles []string
+	Files          []*ast.File
+}
+
+//returns errors which can be fixed with their fixers. Other errors are skipped
+func (c *errorClassifier) classify(errs []types.Error) []classifiedError {
+	classified := []classifiedError{}
+	for i, err := range errs {
+		switch {
+		case isUndeclaredName(err):
+			if fix, name := c.classifyUndeclared(err); fix != "" {
+				classified = append(classified, classifiedError{Error: err, Fix: fix, Name: name})
+			}
+		case isRedeclared(err):
+			//redeclaration is followed by error which points to other declaration.
+			//Declarations from Aggretastic package files are never removed
+			duplicate := err
+			if c.isProtected(err) {
+				if i+1 >= len(errs) || !isOtherDeclaration(errs[i+1]) || c.isProtected(errs[i+1]) {
+					continue
+				}
+				duplicate = errs[i+1]
+			}
+			classified = append(classified, classifiedError{Error: duplicate, Fix: fixRemoveDuplicate})
+		case isUnusedImport(err):
+			classified = append(classified, classifiedError{Error: err, Fix: fixDropImport})
+		case isDuplicateMethod(err):
+			//methods of copied receiver types can be declared in build package too
+			if method := c.methodAt(err.Pos); method != "" {
+				classified = append(classified, classifiedError{Error: err, Fix: fixDropMethod, Name: method})
+			}
+		}
+	}
+	return classified
+}
+
+//exported upstream names are qualified. Unexported ones and types used as
+//method receivers can't be referenced from other package, so they are copied
+func (c *errorClassifier) classifyUndeclared(err types.Error) (string, string) {
+	name := err.Msg[strings.Index(err.Msg, ":")+1:]
+	name = strings.TrimSpace(name)
+	if c.Upstream == nil {
+		return "", name
+	}
+
+	object := c.Upstream.Scope().Lookup(name)
+	if object == nil {
+		return "", name
+	}
+	if !object.Exported() || c.isReceiver(err.Pos) {
+		return fixCopyDeclaration, name
+	}
+	return fixQualify, name
+}
+
+//checks if position is inside method receiver
+func (c *errorClassifier) isReceiver(position token.Pos) bool {
+	for _, file := range c.Files {
+		for _, decl := range file.Decls {
+			function, ok := decl.(*ast.FuncDecl)
+			if ok && function.Recv != nil && function.Recv.Pos() <= position && position < function.Recv.End() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//returns name of method declared at position ("T.Method") or empty string
+func (c *errorClassifier) methodAt(position token.Pos) string {
+	for _, file := range c.Files {
+		for _, decl := range file.Decls {
+			function, ok := decl.(*ast.FuncDecl)
+			if !ok || function.Recv == nil || function.Name.Pos() != position {
+				continue
+			}
+			if receiver := receiverIdent(function); receiver != nil {
+				return receiver.Name + "." + function.Name.Name
+			}
+		}
+	}
+	return ""
+}
+
+//checks if error is reported in Aggretastic package file
+func (c *errorClassifier) isProtected(err types.Error) bool {
+	filename := filepath.Base(err.Fset.Position(err.Pos).Filename)
+	for _, protected := range c.ProtectedFiles {
+		if filename == filepath.Base(protected) {
+			return true
+		}
+	}
+	return false
+}
+
+//checks if error is reported for identifier which is not declared in package
+func isUndeclaredName(err types.Error) bool {
+	//older go/types versions report "undeclared name", newer ones "undefined"
+	return !err.Soft && (strings.HasPrefix(err.Msg, "undeclared name") || strings.HasPrefix(err.Msg, "undefined:"))
+}
+
+func isRedeclared(err types.Error) bool {
+	return strings.HasSuffix(err.Msg, "redeclared in this block")
+}
+
+//older go/types versions report "method M already declared", newer ones "method T.M already declared"
+func isDuplicateMethod(err types.Error) bool {
+	return strings.HasPrefix(err.Msg, "method ") && strings.Contains(err.Msg, " already declared")
+}
+
+func isOtherDeclaration(err types.Error) bool {
+	return strings.HasPrefix(strings.TrimSpace(err.Msg), "other declaration of")
+}
+
+//older go/types versions report "imported but not used", newer ones "imported and not used"
+func isUnusedImport(err types.Error) bool {
+	return strings.Contains(err.Msg, " imported ") && strings.HasSuffix(err.Msg, "not used")
+}
@@ -1,147 +1,183 @@
 package olivere_v6_pipelines
 
 import (
-	"fmt"
-	"github.com/konovenschi/aggretastic-sync/errors"
+	"github.com/dave/dst"
 	"github.com/konovenschi/aggretastic-sync/pretty_dst"
-	"github.com/dlclark/regexp2"
 	"go/ast"
-	"go/parser"
-	"go/printer"
 	"go/token"
-	"gopkg.in/src-d/go-billy.v4"
-	"strconv"
+	"go/types"
+	"golang.org/x/tools/go/ast/astutil"
+	"path/filepath"
 )
 
 
 
+//fixes all given errors of single file. Errors are located in ast restored for type check,
+//corrections are applied to dst of the file, so comments and formatting are kept
 type errorCorrectionPipeline struct {
-	Err               string
+	Filename          string
+	Errs              []classifiedError
 	OriginPackagePath string
 	OriginPackageName string
-	FS                billy.Filesystem
-	filename          string
-	line              int
-	column            int
+	Source            *pretty_dst.Source
+	Nodes             map[ast.Node]dst.Node
+	Upstream          *types.Package
+	Info              *types.Info
 	fileSet           *token.FileSet
 	ast               *ast.File
+	qualifications    []qualification
 }
 
 //runs error correction pipeline
 func (ec *errorCorrectionPipeline) Run() {
-	ec.parseError()
-	ec.parseFile()
-	ec.ensureImports()
-	ec.fixError()
-	ec.saveFile()
+	qualified := false
+	for _, err := range ec.Errs {
+		position := err.Fset.Position(err.Pos)
+		switch err.Fix {
+		case fixQualify:
+			qualified = ec.qualify(position.Line, position.Column) || qualified
+		case fixRemoveDuplicate:
+			ec.removeDeclaration(position.Line, position.Column)
+		case fixDropImport:
+			ec.dropImport(position.Line, position.Column)
+		}
+	}
+	if qualified {
+		ec.ensureImports()
+	}
 }
 
-//extract filename, col and line from error message
-func (ec *errorCorrectionPipeline) parseError() {
-	var err error
-	//compile regex for col/line
-	findLineColRegex := regexp2.MustCompile(":\\d*", 0)
-
-	//get line and filename
-	group, _ := findLineColRegex.FindStringMatch(ec.Err)
-	ec.filename = ec.Err[:group.Index]
-	ec.line, err = strconv.Atoi(group.String()[1:])
-	errors.PanicOnError(errCantAtoi, err)
-
-	//get column
-	group, _ = findLineColRegex.FindNextMatch(group)
-	ec.column, err = strconv.Atoi(group.String()[1:])
-	errors.PanicOnError(errCantAtoi, err)
+//ensure that we have only one given import declaration. Import manager doesn't duplicate existing imports
+func (ec *errorCorrectionPipeline) ensureImports() {
+	ec.Source.AddImport("", ec.OriginPackagePath)
+	ec.Source.SortImports()
 }
 
-func (ec *errorCorrectionPipeline) getSource() billy.File {
-	reader, err := ec.FS.Open(ec.filename)
-	errors.PanicOnError(errCantOpenFile, err)
-	return reader
+//rewrite identifier at position to selector of upstream package. Only identifiers which
+//are not resolved in build package and refer to exported upstream object are qualified
+func (ec *errorCorrectionPipeline) qualify(line int, column int) bool {
+	qualified := false
+	astutil.Apply(ec.ast, func(cursor *astutil.Cursor) bool {
+		ident, ok := cursor.Node().(*ast.Ident)
+		if !ok {
+			return !qualified
+		}
+		if !ec.isAt(ident, line, column) || !ec.isQualifiable(cursor, ident) {
+			return false
+		}
+		if target, ok := ec.Nodes[ident].(*dst.Ident); ok {
+			qualified = ec.Source.QualifyIdentifier(target, ec.OriginPackageName)
+		}
+		if qualified {
+			object := ec.Upstream.Scope().Lookup(ident.Name)
+			//line orders qualifications of file, final line is taken from saved file
+			ec.qualifications = append(ec.qualifications, newQualification(filepath.Base(ec.Filename), line, object))
+		}
+		return false
+	}, nil)
+	return qualified
 }
 
-//extract AST from file
-func (ec *errorCorrectionPipeline) parseFile() {
-	ec.fileSet = token.NewFileSet()
+//checks if identifier is an unresolved reference to exported upstream object
+func (ec *errorCorrectionPipeline) isQualifiable(cursor *astutil.Cursor, ident *ast.Ident) bool {
+	//declared or shadowed names, field and method names are resolved by type checker
+	if ec.Info.Defs[ident] != nil || ec.Info.Uses[ident] != nil {
+		return false
+	}
+	if _, ok := cursor.Parent().(*ast.SelectorExpr); ok && cursor.Name() == "Sel" {
+		return false
+	}
+	//keys of struct literals are field names, only map and slice keys are expressions
+	if _, ok := cursor.Parent().(*ast.KeyValueExpr); ok && cursor.Name() == "Key" && !ec.isExpressionKey(cursor) {
+		return false
+	}
 
-	var err error
-	ec.ast, err = parser.ParseFile(ec.fileSet, ec.filename, ec.getSource(), parser.ParseComments)
-	errors.PanicOnError(errCantParseFile, err)
+	if ec.Upstream == nil {
+		return false
+	}
+	object := ec.Upstream.Scope().Lookup(ident.Name)
+	return object != nil && object.Exported()
 }
 
-//ensure that we have only one given import declaration
-func (ec *errorCorrectionPipeline) ensureImports() {
-	if ec.ast.Imports != nil {
-		for _, imp := range ec.ast.Imports {
-			//do not change anything if package already imported
-			if imp.Path.Value == fmt.Sprintf("\"%s\"", ec.OriginPackagePath) {
-				return
+//checks if key of composite literal element is an expression
+func (ec *errorCorrectionPipeline) isExpressionKey(cursor *astutil.Cursor) bool {
+	var literal *ast.CompositeLit
+	ast.Inspect(ec.ast, func(node ast.Node) bool {
+		if candidate, ok := node.(*ast.CompositeLit); ok {
+			for _, element := range candidate.Elts {
+				if element == cursor.Parent() {
+					literal = candidate
+				}
 			}
 		}
+		return literal == nil
+	})
+	if literal == nil {
+		return false
+	}
+	typeAndValue, ok := ec.Info.Types[literal]
+	if !ok || typeAndValue.Type == nil {
+		return false
 	}
-	//add import to file and regenerate ast tree
-	ec.addImport()
-	ec.parseFile()
+	_, isStruct := typeAndValue.Type.Underlying().(*types.Struct)
+	return !isStruct
 }
 
-//add import to ast and save to disk
-func (ec *errorCorrectionPipeline) addImport() {
-	src := pretty_dst.NewDst(ec.getSource())
-
-	src.AddImport("", ec.OriginPackagePath)
-	file, err := ec.FS.Create(ec.filename)
-	errors.PanicOnError(errCantOpenFile, err)
-
-	err = src.Save(file)
-	errors.PanicOnError(errCantWriteFile, err)
-
-	err = file.Close()
-	errors.PanicOnError(errCantCloseFile, err)
+//remove top-level declaration which name is at position. Grouped declarations lose only matching spec
+func (ec *errorCorrectionPipeline) removeDeclaration(line int, column int) bool {
+	for _, decl := range ec.ast.Decls {
+		switch declaration := decl.(type) {
+		case *ast.FuncDecl:
+			if declaration.Recv == nil && ec.isAt(declaration.Name, line, column) {
+				return ec.Source.RemoveDeclaration(ec.Nodes[declaration])
+			}
+		case *ast.GenDecl:
+			for _, spec := range declaration.Specs {
+				if ec.declaresAt(spec, line, column) {
+					return ec.Source.RemoveDeclaration(ec.Nodes[spec])
+				}
+			}
+		}
+	}
+	return false
 }
 
-//find error pointer in ast and fix error
-func (ec *errorCorrectionPipeline) fixError() {
-	vis := &errorSleuth{
-		column:      ec.column,
-		line:        ec.line,
-		fset:        ec.fileSet,
-		packageName: ec.OriginPackageName,
+//remove import spec at position
+func (ec *errorCorrectionPipeline) dropImport(line int, column int) bool {
+	//restored ast doesn't contain list of imports, so they are searched in declarations
+	for _, decl := range ec.ast.Decls {
+		declaration, ok := decl.(*ast.GenDecl)
+		if !ok || declaration.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range declaration.Specs {
+			if ec.contains(spec, line, column) {
+				return ec.Source.RemoveDeclaration(ec.Nodes[spec])
+			}
+		}
 	}
-	ast.Walk(vis, ec.ast)
+	return false
 }
 
-//save changes on disk
-func (ec *errorCorrectionPipeline) saveFile() {
-	f, err := ec.FS.Create(ec.filename)
-	errors.PanicOnError(errCantOpenFile, err)
-	defer func() {
-		err := f.Close()
-		errors.PanicOnError(errCantCloseFile, err)
-	}()
-
-	err = printer.Fprint(f, ec.fileSet, ec.ast)
-	errors.PanicOnError(errCantWriteFile, err)
+//checks if spec declares name at position
+func (ec *errorCorrectionPipeline) declaresAt(spec ast.Spec, line int, column int) bool {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return ec.isAt(s.Name, line, column)
+	case *ast.ValueSpec:
+		//specs with several names can't be removed partially
+		return len(s.Names) == 1 && ec.isAt(s.Names[0], line, column)
+	}
+	return false
 }
 
-//implementation of ast.Visitor interface
-type errorSleuth struct {
-	column      int
-	line        int
-	fset        *token.FileSet
-	packageName string
+func (ec *errorCorrectionPipeline) isAt(node ast.Node, line int, column int) bool {
+	position := ec.fileSet.Position(node.Pos())
+	return position.Line == line && position.Column == column
 }
 
-//Find error pointer and fix error
-func (v *errorSleuth) Visit(node ast.Node) ast.Visitor {
-	n, ok := node.(*ast.Ident)
-	if !ok {
-		return v
-	}
-
-	//fix error if position matches
-	position := v.fset.Position(n.Pos())
-	if position.Line == v.line && position.Column == v.column {
-		n.Name = fmt.Sprintf("%s.%s", v.packageName, n.Name)
-	}
-	return v
+func (ec *errorCorrectionPipeline) contains(node ast.Node, line int, column int) bool {
+	start := ec.fileSet.Position(node.Pos())
+	end := ec.fileSet.Position(node.End())
+	return start.Line == line && start.Column <= column && column < end.Column
 }
@@ -1,17 +1,22 @@
 package olivere_v6_pipelines
 
 import (
+	"fmt"
 	"github.com/dave/dst"
 	"github.com/konovenschi/aggretastic-sync/errors"
 	"github.com/konovenschi/aggretastic-sync/pretty_dst"
 	"go/token"
-	"gopkg.in/src-d/go-billy.v4"
+	"path/filepath"
 	"strings"
 )
 
+//header which marks file as generated for go tooling. Filled with upstream path and commit
+const generatedHeader = "// Code generated by aggretastic-sync from olivere/elastic %s@%s. DO NOT EDIT."
+
 type fileUpdatePipeline struct {
 	Filename string
-	FS       billy.Filesystem
+	Package  *pretty_dst.Package
+	Commit   string
 	src      *pretty_dst.Source
 
 	DesiredPackageName string
@@ -20,24 +25,37 @@ type fileUpdatePipeline struct {
 	structure                  *pretty_dst.StructureDeclaration
 	structureInitExpression    *dst.UnaryExpr
 
-	TargetFunctionNamePattern string
-	function                  *pretty_dst.Function
-	functionBody              *pretty_dst.FunctionBody
+	function     *pretty_dst.Function
+	functionBody *pretty_dst.FunctionBody
 
 	strategy modificationStrategy
+
+	Overrides *aggregationOverrides
+
+	//enriched aggregation types (final names after rename) with strategy names, filled by run
+	Enriched map[string]string
 }
 
 //Run file updater pipeline
 func (fu *fileUpdatePipeline) Run() {
 	fu.parseFile()
-	defer fu.saveFile()
-
+	//tests of excluded file are removed together with it
+	if fu.src == nil {
+		return
+	}
 	fu.renamePackage()
 	fu.findTargetStructure()
+	if fu.isExcluded() {
+		fu.removeFile()
+		return
+	}
+	defer fu.saveFile()
+
 	if fu.structure == nil {
 		return
 	}
 	fu.pickStrategy()
+	fu.renameStructure()
 	fu.enrichStructure()
 
 	fu.findTargetFunction()
@@ -47,31 +65,36 @@ func (fu *fileUpdatePipeline) Run() {
 	fu.enrichFunction()
 }
 
-//parse ast from file
+//take parsed file from package
 func (fu *fileUpdatePipeline) parseFile() {
-	file, err := fu.FS.Open(fu.Filename)
-	errors.PanicOnError(errCantOpenFile, err)
-
-	fu.src = pretty_dst.NewDst(file)
+	fu.src = fu.Package.File(fu.Filename)
 }
 
-//save ast changes on disk without search_ prefix
+//keep ast changes in package without search_ prefix. Package is saved after all files are updated
 func (fu *fileUpdatePipeline) saveFile() {
-	filename := strings.Replace(fu.Filename, "search_aggs_", "aggs_", -1)
-	file, err := fu.FS.Create(filename)
-	errors.PanicOnError(errCantOpenFile, err)
-	defer func() {
-		err := file.Close()
-		errors.PanicOnError(errCantCloseFile, err)
-	}()
-
-	err = fu.src.Save(file)
-	errors.PanicOnError(errCantWriteFile, err)
-
-	if filename != fu.Filename {
-		err = fu.FS.Remove(fu.Filename)
-		errors.PanicOnError(errCantRemoveFile, err)
+	fu.cleanImports()
+	fu.addGeneratedHeader()
+	fu.Package.RenameFile(fu.Filename, strings.Replace(fu.Filename, "search_aggs_", "aggs_", -1))
+}
+
+//remove aliased imports which became unused after modifications and group the rest.
+//Other unused imports are removed by cleanup after type check
+func (fu *fileUpdatePipeline) cleanImports() {
+	for _, imp := range fu.src.UnusedAliasedImports() {
+		fu.src.RemoveImport(pretty_dst.ImportPath(imp))
 	}
+	fu.src.SortImports()
+}
+
+//remove excluded file and its tests from build path, tests reference excluded type
+func (fu *fileUpdatePipeline) removeFile() {
+	fu.Package.RemoveFile(fu.Filename)
+	fu.Package.RemoveFile(strings.TrimSuffix(fu.Filename, ".go") + "_test.go")
+}
+
+//mark file as generated from upstream file. License comments are kept below the header
+func (fu *fileUpdatePipeline) addGeneratedHeader() {
+	fu.src.PrependFileComment(fmt.Sprintf(generatedHeader, filepath.Base(fu.Filename), fu.Commit))
 }
 
 func (fu *fileUpdatePipeline) renamePackage() {
@@ -82,47 +105,107 @@ func (fu *fileUpdatePipeline) findTargetStructure() {
 	fu.structure = fu.src.FindStructure(fu.TargetStructureNamePattern)
 }
 
+//check if target structure is excluded by overrides
+func (fu *fileUpdatePipeline) isExcluded() bool {
+	if fu.structure == nil || !fu.Overrides.isExcluded(fu.structure.GetName()) {
+		return false
+	}
+	fu.Overrides.decide(fu.structure.GetName(), filepath.Base(fu.Filename), "excluded")
+	return true
+}
+
+//rename target structure and its references in file if override exists
+func (fu *fileUpdatePipeline) renameStructure() {
+	name := fu.structure.GetName()
+	newName, ok := fu.Overrides.renameTo(name)
+	if !ok {
+		return
+	}
+	fu.Package.Rename(name, newName, true)
+	fu.Overrides.decide(name, filepath.Base(fu.Filename), "renamed to "+newName)
+}
+
 func (fu *fileUpdatePipeline) enrichStructure() {
 	fu.strategy.enrichStructure(fu.structure)
+	fu.Enriched[fu.structure.GetName()] = fu.strategy.name()
 }
 
+//find constructor of target structure by its result type, so other constructors in file are not changed
 func (fu *fileUpdatePipeline) findTargetFunction() {
-	fu.function = fu.src.FindFunction(fu.TargetFunctionNamePattern)
-	fu.functionBody = fu.function.GetBody()
+	fu.function = fu.src.FindConstructor(fu.structure.GetName())
+	if fu.function != nil {
+		fu.functionBody = fu.function.GetBody()
+	}
 }
 
+//enrich constructor in place: custom field is initialized before return, upstream logic is kept.
+//Constructors which don't return structure pointer or variable are left as is
 func (fu *fileUpdatePipeline) enrichFunction() {
-	fu.findStructureInitExpression()
-	fu.functionBody.Wipe()
-	fu.functionBody.AppendNewAssigment("a", token.DEFINE, fu.structureInitExpression)
-	fu.strategy.initCustomField(fu.functionBody)
-	newReturn := pretty_dst.NewIdent("a", nil)
-	fu.functionBody.AppendNewReturn(newReturn)
+	receiver := fu.findStructureInitExpression()
+	if receiver == "" {
+		return
+	}
+	fu.strategy.initCustomField(fu.functionBody, receiver)
 }
 
-func (fu *fileUpdatePipeline) findStructureInitExpression() {
-	ret := fu.functionBody.GetFirstReturn()
+//find structure initialization in constructor and remove subAggregations from it.
+//Returned literal is moved to variable, so custom field can be initialized. Returns variable name
+//or empty string if constructor has no suitable return
+func (fu *fileUpdatePipeline) findStructureInitExpression() string {
+	ret := fu.functionBody.GetLastReturn()
+	if ret == nil {
+		return ""
+	}
 	if id := ret.GetIdentifier(); id != nil {
-		assigment := fu.functionBody.GetFirstAssignment()
-		fu.structureInitExpression = assigment.Rhs[0].(*dst.UnaryExpr)
-	} else {
-		ret.GetUnaryExpression().GetCompositeLiteral().RemoveElementByKey("subAggregations")
-		fu.structureInitExpression = ret.GetUnaryExpression().UnaryExpr
+		initialization := fu.functionBody.FindStatement(func(statement dst.Stmt) bool {
+			return pretty_dst.IsAssignmentTo(id.Name)(statement) || pretty_dst.IsDeclarationOf(id.Name)(statement)
+		})
+		if initialization != nil {
+			fu.structureInitExpression, _ = pretty_dst.InitialValue(initialization, id.Name).(*dst.UnaryExpr)
+			fu.removeSubAggregations()
+		}
+		return id.Name
+	}
+
+	expression := ret.GetUnaryExpression()
+	if expression == nil {
+		return ""
+	}
+	fu.structureInitExpression = expression.UnaryExpr
+	fu.removeSubAggregations()
+	fu.functionBody.Replace(ret.ReturnStmt,
+		pretty_dst.NewAssigment("a", token.DEFINE, fu.structureInitExpression),
+		pretty_dst.NewReturnStatement(pretty_dst.NewIdent("a", nil)),
+	)
+	return "a"
+}
+
+func (fu *fileUpdatePipeline) removeSubAggregations() {
+	if fu.structureInitExpression == nil {
+		return
+	}
+	if literal := pretty_dst.DecorateUnaryExpression(fu.structureInitExpression).GetCompositeLiteral(); literal != nil {
+		literal.RemoveElementByKey("subAggregations")
 	}
 }
 
-//pick modification stratedy. Depends on target structure fieldset
+//pick modification stratedy. Depends on target structure fieldset or overrides
 func (fu *fileUpdatePipeline) pickStrategy() {
-	if fu.structure.IsFieldExists("subAggregations") {
+	decision := ""
+	if forced := fu.Overrides.forcedStrategy(fu.structure.GetName()); forced != nil {
+		fu.strategy = forced
+		decision = " (forced)"
+	} else if fu.structure.IsFieldExists("subAggregations") {
 		fu.strategy = injectableStrategy{}
 	} else {
 		fu.strategy = notInjectableStrategy{}
 	}
+	fu.Overrides.decide(fu.structure.GetName(), filepath.Base(fu.Filename), fu.strategy.name()+decision)
 }
 
 type modificationStrategy interface {
 	enrichStructure(*pretty_dst.StructureDeclaration)
-	initCustomField(*pretty_dst.FunctionBody)
+	initCustomField(body *pretty_dst.FunctionBody, receiver string)
 	name() string
 }
 
@@ -131,15 +214,14 @@ type injectableStrategy struct {
 }
 
 func (injectableStrategy) enrichStructure(structure *pretty_dst.StructureDeclaration) {
-	structure.AddField("*Injectable", "")
+	structure.AddEmbeddedField("*Injectable")
 	_ = structure.RemoveField("subAggregations")
 }
 
-func (injectableStrategy) initCustomField(body *pretty_dst.FunctionBody) {
-	body.AppendNewAssigment("a.Injectable",
-		token.ASSIGN,
-		pretty_dst.NewCallExpression("newInjectable", "a"),
-	)
+func (injectableStrategy) initCustomField(body *pretty_dst.FunctionBody, receiver string) {
+	statement, err := pretty_dst.ParseStatement("$recv.Injectable = newInjectable($recv)", pretty_dst.Placeholders{"recv": receiver})
+	errors.PanicOnError(errBrokenTemplate, err)
+	body.InsertBefore(body.GetLastReturn().ReturnStmt, statement)
 }
 
 func (injectableStrategy) name() string {
@@ -151,14 +233,13 @@ type notInjectableStrategy struct {
 }
 
 func (notInjectableStrategy) enrichStructure(structure *pretty_dst.StructureDeclaration) {
-	structure.AddField("*NotInjectable", "")
+	structure.AddEmbeddedField("*NotInjectable")
 }
 
-func (notInjectableStrategy) initCustomField(body *pretty_dst.FunctionBody) {
-	body.AppendNewAssigment("a.NotInjectable",
-		token.ASSIGN,
-		pretty_dst.NewCallExpression("newNotInjectable", "a"),
-	)
+func (notInjectableStrategy) initCustomField(body *pretty_dst.FunctionBody, receiver string) {
+	statement, err := pretty_dst.ParseStatement("$recv.NotInjectable = newNotInjectable($recv)", pretty_dst.Placeholders{"recv": receiver})
+	errors.PanicOnError(errBrokenTemplate, err)
+	body.InsertBefore(body.GetLastReturn().ReturnStmt, statement)
 }
 
 func (notInjectableStrategy) name() string {
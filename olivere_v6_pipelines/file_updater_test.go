@@ -0,0 +1,137 @@
+package olivere_v6_pipelines
+
+import (
+	"github.com/konovenschi/aggretastic-sync/pretty_dst"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const testBuildPath = "build/"
+
+//writes files into build path of in-memory filesystem
+func newTestFS(t *testing.T, files map[string]string) billy.Filesystem {
+	fs := memfs.New()
+	for name, content := range files {
+		writeFSFile(t, fs, testBuildPath+name, content)
+	}
+	return fs
+}
+
+//creates file in filesystem
+func writeFSFile(t *testing.T, fs billy.Filesystem, filename string, content string) {
+	file, err := fs.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//returns content of file in filesystem
+func readTestFile(t *testing.T, fs billy.Filesystem, filename string) string {
+	file, err := fs.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+//returns sorted names of files in build path
+func buildFiles(t *testing.T, fs billy.Filesystem) []string {
+	entries, err := fs.ReadDir(testBuildPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+//runs file updater for every file of build path like package updater does
+func runFileUpdater(t *testing.T, fs billy.Filesystem, overrides *aggregationOverrides) map[string]string {
+	pkg := pretty_dst.NewPackage(fs, testBuildPath)
+	if err := pkg.Load(); err != nil {
+		t.Fatal(err)
+	}
+	enriched := map[string]string{}
+	for _, filename := range pkg.Filenames() {
+		fu := fileUpdatePipeline{
+			Filename:                   filename,
+			DesiredPackageName:         aggretasticPackageName,
+			TargetStructureNamePattern: aggregationNamePattern,
+			Package:                    pkg,
+			Commit:                     "abc",
+			Overrides:                  overrides,
+			Enriched:                   enriched,
+		}
+		fu.Run()
+	}
+	if err := pkg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return enriched
+}
+
+func TestFileUpdaterExclusion(t *testing.T) {
+	files := map[string]string{
+		"search_aggs_avg.go":        "package elastic\n\ntype AvgAggregation struct {\n\tsubAggregations map[string]Aggregation\n}\n",
+		"search_aggs_avg_test.go":   "package elastic\n\nvar _ = AvgAggregation{}\n",
+		"search_aggs_terms.go":      "package elastic\n\ntype TermsAggregation struct {\n\tsubAggregations map[string]Aggregation\n}\n",
+		"search_aggs_terms_test.go": "package elastic\n\nvar _ = TermsAggregation{}\n",
+	}
+
+	tests := []struct {
+		name     string
+		exclude  []string
+		files    []string
+		enriched map[string]string
+	}{
+		{
+			name:     "nothing excluded",
+			exclude:  nil,
+			files:    []string{"aggs_avg.go", "aggs_avg_test.go", "aggs_terms.go", "aggs_terms_test.go"},
+			enriched: map[string]string{"AvgAggregation": "Injectable", "TermsAggregation": "Injectable"},
+		},
+		{
+			name:     "excluded type is removed with its tests",
+			exclude:  []string{"AvgAggregation"},
+			files:    []string{"aggs_terms.go", "aggs_terms_test.go"},
+			enriched: map[string]string{"TermsAggregation": "Injectable"},
+		},
+		{
+			name:     "all types excluded",
+			exclude:  []string{"AvgAggregation", "TermsAggregation"},
+			files:    []string{},
+			enriched: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := newTestFS(t, files)
+			enriched := runFileUpdater(t, fs, newAggregationOverrides(test.exclude, nil, nil, nil))
+			if actual := buildFiles(t, fs); !reflect.DeepEqual(actual, test.files) {
+				t.Errorf("expected files %v, got %v", test.files, actual)
+			}
+			if !reflect.DeepEqual(enriched, test.enriched) {
+				t.Errorf("expected enriched types %v, got %v", test.enriched, enriched)
+			}
+		})
+	}
+}
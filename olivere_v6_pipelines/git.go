@@ -12,8 +12,13 @@ type gitPipeline struct {
 	Lock   string
 }
 
-//run git pipeline
-func (g *gitPipeline) Run() (bool, billy.Filesystem) {
+//returns commit hash of previous sync from head lock
+func (g *gitPipeline) previousCommit() string {
+	return git.ReadLockFile(g.Lock)
+}
+
+//run git pipeline. Returns up-to-date flag, worktree filesystem and synced commit hash
+func (g *gitPipeline) Run() (bool, billy.Filesystem, string) {
 	repository, err := git.Clone(g.Url, g.Branch)
 	errors.PanicOnError(errCantClone, err)
 
@@ -29,5 +34,5 @@ func (g *gitPipeline) Run() (bool, billy.Filesystem) {
 	fs, err := repository.Worktree()
 	errors.PanicOnError(errBrokenStorage, err)
 
-	return isUpToDate, fs.Filesystem
+	return isUpToDate, fs.Filesystem, head.Hash().String()
 }
@@ -0,0 +1,169 @@
+package olivere_v6_pipelines
+
+import (
+	"github.com/konovenschi/aggretastic-sync/pretty_dst"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"gopkg.in/src-d/go-billy.v4"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+//types.Importer which type-checks upstream package and its vendored dependencies
+//directly from cloned repository. Standard library is imported by fallback importer,
+//other packages which are not in repository can't be imported and stop the pipeline
+type sourceImporter struct {
+	FS          billy.Filesystem
+	PackagePath string
+	Root        string
+	fileSet     *token.FileSet
+	packages    map[string]*types.Package
+	sources     map[string]*packageSource
+	fallback    types.Importer
+	context     build.Context
+}
+
+//creates importer for upstream package cloned into fs
+func newSourceImporter(fs billy.Filesystem, packagePath string) *sourceImporter {
+	si := &sourceImporter{
+		FS:          fs,
+		PackagePath: packagePath,
+		Root:        ".",
+		fileSet:     token.NewFileSet(),
+		packages:    map[string]*types.Package{},
+		sources:     map[string]*packageSource{},
+		fallback:    importer.Default(),
+	}
+
+	//build context reads files from cloned repository, so build tags are respected
+	si.context = build.Default
+	si.context.OpenFile = func(name string) (io.ReadCloser, error) {
+		return fs.Open(name)
+	}
+	si.context.ReadDir = fs.ReadDir
+	si.context.IsDir = func(name string) bool {
+		info, err := fs.Stat(name)
+		return err == nil && info.IsDir()
+	}
+	return si
+}
+
+//parsed sources and type information of package imported from cloned repository
+type packageSource struct {
+	Files    []*ast.File
+	Contents map[string][]byte
+	Info     *types.Info
+}
+
+//implementation of types.Importer
+func (si *sourceImporter) Import(importPath string) (*types.Package, error) {
+	if pkg, ok := si.packages[importPath]; ok {
+		return pkg, nil
+	}
+	if importPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+
+	if dir, ok := si.lookupDir(importPath); ok {
+		return si.importDir(importPath, dir)
+	}
+
+	//type errors are tolerated by checker, so missing package has to stop pipeline here,
+	//otherwise declarations which depend on it are silently lost
+	if !pretty_dst.IsStandardImport(importPath) {
+		panic(errMissingImport.Error() + importPath + " is neither upstream package nor vendored")
+	}
+	pkg, err := si.fallback.Import(importPath)
+	if err != nil {
+		panic(errMissingImport.Error() + importPath + ": " + err.Error())
+	}
+	si.packages[importPath] = pkg
+	return pkg, nil
+}
+
+//returns sources of package imported from cloned repository or nil
+func (si *sourceImporter) source(importPath string) *packageSource {
+	if _, err := si.Import(importPath); err != nil {
+		return nil
+	}
+	return si.sources[importPath]
+}
+
+//find directory of upstream package, its subpackage or vendored dependency
+func (si *sourceImporter) lookupDir(importPath string) (string, bool) {
+	if importPath == si.PackagePath {
+		return si.Root, true
+	}
+	if strings.HasPrefix(importPath, si.PackagePath+"/") {
+		return path.Join(si.Root, strings.TrimPrefix(importPath, si.PackagePath+"/")), true
+	}
+	vendored := path.Join(si.Root, "vendor", importPath)
+	if si.context.IsDir(vendored) {
+		return vendored, true
+	}
+	return "", false
+}
+
+//parse and type-check package sources. Errors are tolerated, so partially
+//broken upstream still provides declarations for error correction
+func (si *sourceImporter) importDir(importPath string, dir string) (*types.Package, error) {
+	entries, err := si.FS.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &packageSource{
+		Contents: map[string][]byte{},
+		Info: &types.Info{
+			Defs: make(map[*ast.Ident]types.Object),
+			Uses: make(map[*ast.Ident]types.Object),
+		},
+	}
+	for _, entry := range entries {
+		if !si.isPackageFile(dir, entry) {
+			continue
+		}
+		filename := path.Join(dir, entry.Name())
+		file, err := si.FS.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, err
+		}
+		astFile, err := parser.ParseFile(si.fileSet, filename, content, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		source.Files = append(source.Files, astFile)
+		source.Contents[filename] = content
+	}
+
+	config := types.Config{
+		Importer: si,
+		Error:    func(error) {},
+	}
+	pkg, _ := config.Check(importPath, si.fileSet, source.Files, source.Info)
+	si.packages[importPath] = pkg
+	si.sources[importPath] = source
+	return pkg, nil
+}
+
+//checks if file is non-test go file which matches build constraints
+func (si *sourceImporter) isPackageFile(dir string, entry os.FileInfo) bool {
+	name := entry.Name()
+	if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	match, err := si.context.MatchFile(dir, name)
+	return err == nil && match
+}
@@ -0,0 +1,57 @@
+package olivere_v6_pipelines
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSourceImporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		missing string
+	}{
+		{
+			name: "vendored and standard packages",
+			files: map[string]string{
+				"elastic.go":                 "package elastic\n\nimport (\n\t\"errors\"\n\t\"github.com/a/b\"\n)\n\nvar X = b.Y\nvar E = errors.New(\"e\")\n",
+				"vendor/github.com/a/b/b.go": "package b\n\nvar Y = 1\n",
+			},
+		},
+		{
+			name: "missing package",
+			files: map[string]string{
+				"elastic.go": "package elastic\n\nimport \"github.com/a/b\"\n\nvar X = b.Y\n",
+			},
+			missing: "github.com/a/b",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := newTestFS(t, map[string]string{})
+			for name, content := range test.files {
+				writeFSFile(t, fs, name, content)
+			}
+			si := newSourceImporter(fs, elasticPackagePath)
+
+			defer func() {
+				recovered := recover()
+				message, _ := recovered.(string)
+				if test.missing == "" && recovered != nil {
+					t.Errorf("unexpected panic: %v", recovered)
+				}
+				if test.missing != "" && !strings.Contains(message, test.missing) {
+					t.Errorf("expected panic naming %s, got %v", test.missing, recovered)
+				}
+			}()
+			pkg, err := si.Import(elasticPackagePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pkg.Scope().Lookup("X") == nil {
+				t.Error("upstream declaration is not found")
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package olivere_v6_pipelines
+
+import (
+	"fmt"
+	"github.com/dave/dst"
+	"github.com/dave/jennifer/jen"
+	"github.com/konovenschi/aggretastic-sync/errors"
+	"github.com/konovenschi/aggretastic-sync/pretty_dst"
+	"go/token"
+	"gopkg.in/src-d/go-billy.v4"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//header for files which are generated from the whole upstream package
+const generatedPackageHeader = "// Code generated by aggretastic-sync from olivere/elastic@%s. DO NOT EDIT."
+
+//generates registry of aggregation types exposed by Aggretastic
+type indexPipeline struct {
+	BuildPath     string
+	Filename      string
+	PackageName   string
+	InterfaceName string
+	Commit        string
+	FS            billy.Filesystem
+
+	//aggregation types enriched by file updater with their strategies
+	Enriched map[string]string
+	entries  []indexEntry
+	warnings []string
+}
+
+//aggregation type description for index
+type indexEntry struct {
+	Type        string
+	Kind        string
+	Constructor string
+	Strategy    string
+}
+
+//run index pipeline
+func (ip *indexPipeline) Run() {
+	files, err := ip.FS.ReadDir(ip.BuildPath)
+	errors.PanicOnError(errCantReadDir, err)
+
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || name == ip.Filename || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		ip.indexFile(name)
+	}
+
+	//enriched types which are not declared anymore (e.g. removed by patch)
+	indexed := map[string]bool{}
+	for _, entry := range ip.entries {
+		indexed[entry.Type] = true
+	}
+	for name := range ip.Enriched {
+		if !indexed[name] {
+			ip.warnings = append(ip.warnings, fmt.Sprintf("%s: not declared in build package", name))
+		}
+	}
+
+	sort.Slice(ip.entries, func(i, j int) bool {
+		return ip.entries[i].Type < ip.entries[j].Type
+	})
+	ip.saveFile()
+	ip.report()
+}
+
+//collect enriched aggregation types from file
+func (ip *indexPipeline) indexFile(name string) {
+	file, err := ip.FS.Open(ip.BuildPath + name)
+	errors.PanicOnError(errCantOpenFile, err)
+	src := pretty_dst.NewDst(file)
+	err = file.Close()
+	errors.PanicOnError(errCantCloseFile, err)
+
+	//types are taken from enrich stage, so renamed aggregations are indexed too
+	for _, structure := range src.FindStructures(".*") {
+		strategy, ok := ip.Enriched[structure.GetName()]
+		if !ok {
+			continue
+		}
+		entry := indexEntry{Type: structure.GetName(), Strategy: strategy}
+
+		entry.Kind = findSourceKind(src, entry.Type)
+		entry.Constructor = findConstructor(src, entry.Type)
+		if entry.Kind == "" || entry.Constructor == "" {
+			ip.warnings = append(ip.warnings, fmt.Sprintf("%s (%s): kind %q, constructor %q", entry.Type, name, entry.Kind, entry.Constructor))
+		}
+		ip.entries = append(ip.entries, entry)
+	}
+}
+
+//returns aggregation kind from `source["kind"] = ...` assignment in Source() method of type
+func findSourceKind(src *pretty_dst.Source, typeName string) string {
+	kind := ""
+	for _, method := range src.FindFunctions("^Source$") {
+		if method.GetReceiverType() != typeName {
+			continue
+		}
+		dst.Inspect(method.GetBody().BlockStmt, func(node dst.Node) bool {
+			assignment, ok := node.(*dst.AssignStmt)
+			if !ok || kind != "" || len(assignment.Lhs) != 1 {
+				return kind == ""
+			}
+			index, ok := assignment.Lhs[0].(*dst.IndexExpr)
+			if !ok {
+				return true
+			}
+			if target, ok := index.X.(*dst.Ident); !ok || target.Name != "source" {
+				return true
+			}
+			if key, ok := index.Index.(*dst.BasicLit); ok && key.Kind == token.STRING {
+				kind, _ = strconv.Unquote(key.Value)
+			}
+			return kind == ""
+		})
+	}
+	return kind
+}
+
+//returns name of function without arguments which returns pointer to type
+func findConstructor(src *pretty_dst.Source, typeName string) string {
+	for _, function := range src.FindFunctions("^New") {
+		declaration := function.Extract()
+		if function.GetReceiverType() != "" || len(declaration.Type.Params.List) != 0 {
+			continue
+		}
+		results := declaration.Type.Results
+		if results == nil || len(results.List) != 1 {
+			continue
+		}
+		if pointer, ok := results.List[0].Type.(*dst.StarExpr); ok {
+			if ident, ok := pointer.X.(*dst.Ident); ok && ident.Name == typeName {
+				return function.GetName()
+			}
+		}
+	}
+	return ""
+}
+
+//render index file into build path
+func (ip *indexPipeline) saveFile() {
+	file := jen.NewFile(ip.PackageName)
+	file.HeaderComment(fmt.Sprintf(generatedPackageHeader, ip.Commit))
+
+	constructors := jen.Dict{}
+	kinds := map[string]string{}
+	injectable := []jen.Code{}
+	notInjectable := []jen.Code{}
+	for _, entry := range ip.entries {
+		//entries are sorted by type, so the first type of kind is registered and the rest are reported
+		if registered, ok := kinds[entry.Kind]; ok && entry.Constructor != "" {
+			ip.warnings = append(ip.warnings, fmt.Sprintf("%s: kind %q is already registered by %s", entry.Type, entry.Kind, registered))
+		} else if entry.Kind != "" && entry.Constructor != "" {
+			kinds[entry.Kind] = entry.Type
+			constructors[jen.Lit(entry.Kind)] = jen.Func().Params().Id(ip.InterfaceName).Block(
+				jen.Return(jen.Id(entry.Constructor).Call()),
+			)
+		}
+		if entry.Strategy == (injectableStrategy{}).name() {
+			injectable = append(injectable, jen.Line().Lit(entry.Type))
+		} else {
+			notInjectable = append(notInjectable, jen.Line().Lit(entry.Type))
+		}
+	}
+
+	file.Comment("AggregationConstructors maps aggregation kind (key in Source() output) to constructor")
+	file.Var().Id("AggregationConstructors").Op("=").Map(jen.String()).Func().Params().Id(ip.InterfaceName).Values(constructors)
+	file.Line()
+	file.Comment("InjectableAggregations lists aggregation types which accept sub-aggregations")
+	file.Var().Id("InjectableAggregations").Op("=").Index().String().Values(append(injectable, jen.Line())...)
+	file.Line()
+	file.Comment("NotInjectableAggregations lists aggregation types which don't accept sub-aggregations")
+	file.Var().Id("NotInjectableAggregations").Op("=").Index().String().Values(append(notInjectable, jen.Line())...)
+
+	output, err := ip.FS.Create(ip.BuildPath + ip.Filename)
+	errors.PanicOnError(errCantOpenFile, err)
+	defer func() {
+		err := output.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}()
+
+	err = file.Render(output)
+	errors.PanicOnError(errCantWriteFile, err)
+}
+
+//print types which can't be constructed dynamically
+func (ip *indexPipeline) report() {
+	fmt.Printf("Aggregation index: %d types\n", len(ip.entries))
+	if len(ip.warnings) == 0 {
+		return
+	}
+	sort.Strings(ip.warnings)
+	fmt.Println("Aggregations which are not registered in constructors map:")
+	for _, warning := range ip.warnings {
+		fmt.Println("\t" + warning)
+	}
+}
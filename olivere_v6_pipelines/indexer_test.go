@@ -0,0 +1,85 @@
+package olivere_v6_pipelines
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//returns source of aggregation type with constructor and kind
+func testAggregation(name string, kind string) string {
+	return "package aggretastic\n\ntype " + name + " struct{}\n\nfunc New" + name + "() *" + name + " {\n\treturn &" + name + "{}\n}\n\n" +
+		"func (a *" + name + ") Source() (interface{}, error) {\n\tsource := map[string]interface{}{}\n\tsource[\"" + kind + "\"] = nil\n\treturn source, nil\n}\n"
+}
+
+func TestIndexPipeline(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		enriched     map[string]string
+		constructors []string
+		warnings     []string
+	}{
+		{
+			name: "every kind is registered",
+			files: map[string]string{
+				"aggs_avg.go":   testAggregation("AvgAggregation", "avg"),
+				"aggs_terms.go": testAggregation("TermsAggregation", "terms"),
+			},
+			enriched:     map[string]string{"AvgAggregation": "Injectable", "TermsAggregation": "Injectable"},
+			constructors: []string{"NewAvgAggregation", "NewTermsAggregation"},
+			warnings:     []string{},
+		},
+		{
+			name: "duplicate kind is reported",
+			files: map[string]string{
+				"aggs_avg.go":   testAggregation("AvgAggregation", "avg"),
+				"aggs_mean.go":  testAggregation("MeanAggregation", "avg"),
+				"aggs_terms.go": testAggregation("TermsAggregation", "terms"),
+			},
+			enriched:     map[string]string{"AvgAggregation": "Injectable", "MeanAggregation": "Injectable", "TermsAggregation": "Injectable"},
+			constructors: []string{"NewAvgAggregation", "NewTermsAggregation"},
+			warnings:     []string{`MeanAggregation: kind "avg" is already registered by AvgAggregation`},
+		},
+		{
+			name: "enriched type which is not declared",
+			files: map[string]string{
+				"aggs_avg.go": testAggregation("AvgAggregation", "avg"),
+			},
+			enriched:     map[string]string{"AvgAggregation": "Injectable", "SumAggregation": "Injectable"},
+			constructors: []string{"NewAvgAggregation"},
+			warnings:     []string{"SumAggregation: not declared in build package"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := newTestFS(t, test.files)
+			indexer := indexPipeline{
+				BuildPath:     testBuildPath,
+				Filename:      "aggs_index.go",
+				PackageName:   aggretasticPackageName,
+				InterfaceName: aggregationInterfaceName,
+				Commit:        "abc",
+				FS:            fs,
+				Enriched:      test.enriched,
+			}
+			indexer.Run()
+
+			index := readTestFile(t, fs, testBuildPath+"aggs_index.go")
+			constructors := []string{}
+			for _, line := range strings.Split(index, "\n") {
+				if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "return" {
+					constructors = append(constructors, strings.TrimSuffix(fields[1], "()"))
+				}
+			}
+			if !reflect.DeepEqual(constructors, test.constructors) {
+				t.Errorf("expected constructors %v, got %v", test.constructors, constructors)
+			}
+			warnings := append([]string{}, indexer.warnings...)
+			if !reflect.DeepEqual(warnings, test.warnings) {
+				t.Errorf("expected warnings %v, got %v", test.warnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package olivere_v6_pipelines
+
+import (
+	"fmt"
+	"github.com/konovenschi/aggretastic-sync/errors"
+	"github.com/konovenschi/aggretastic-sync/pretty_dst"
+	"go/types"
+	"gopkg.in/src-d/go-billy.v4"
+	"path"
+	"sort"
+	"strings"
+)
+
+//comment which marks methods added by interface sync, so they are removed when upstream drops them
+const syncedMethodComment = "// synced from %s.%s"
+
+//keeps Aggretastic aggregation interface in sync with method set of upstream interface.
+//Missing methods are added and changed signatures are updated, other methods are kept as is
+type interfaceSyncPipeline struct {
+	BuildPath             string
+	Filename              string
+	InterfaceName         string
+	UpstreamPath          string
+	UpstreamPackageName   string
+	UpstreamInterfaceName string
+	Importer              types.Importer
+	FS                    billy.Filesystem
+	changes               []string
+}
+
+//run interface sync pipeline
+func (is *interfaceSyncPipeline) Run() {
+	pkg := pretty_dst.NewPackage(is.FS, is.BuildPath)
+	err := pkg.Load()
+	errors.PanicOnError(errCantReadDir, err)
+
+	src := pkg.File(is.BuildPath + is.Filename)
+	if src == nil {
+		fmt.Printf("Interface sync skipped: %s is not in build path\n", is.Filename)
+		return
+	}
+	iface := src.FindInterface("^" + is.InterfaceName + "$")
+	if iface == nil {
+		panic(errNonConforming.Error() + "interface " + is.InterfaceName + " is not declared in " + is.Filename)
+	}
+	//embedded upstream interface is always in sync
+	if iface.IsEmbedded(is.UpstreamPackageName + "." + is.UpstreamInterfaceName) {
+		return
+	}
+
+	signatures, imports := is.upstreamSignatures()
+	is.update(iface, signatures)
+	is.removeDropped(iface, signatures)
+	if len(imports) > 0 {
+		for importPath, name := range imports {
+			if name == path.Base(importPath) {
+				name = ""
+			}
+			src.AddImport(name, importPath)
+		}
+		src.SortImports()
+	}
+
+	err = pkg.Save()
+	errors.PanicOnError(errCantWriteFile, err)
+	is.report()
+}
+
+//returns signatures of upstream interface methods (including embedded ones) keyed by method name
+//and names of packages referenced by signatures keyed by import path
+func (is *interfaceSyncPipeline) upstreamSignatures() (map[string]string, map[string]string) {
+	upstream, err := is.Importer.Import(is.UpstreamPath)
+	errors.PanicOnError(errTypeCheck, err)
+	object, ok := upstream.Scope().Lookup(is.UpstreamInterfaceName).(*types.TypeName)
+	if !ok {
+		panic(errNonConforming.Error() + "upstream interface " + is.UpstreamInterfaceName + " is not declared")
+	}
+	iface, ok := object.Type().Underlying().(*types.Interface)
+	if !ok {
+		panic(errNonConforming.Error() + "upstream " + is.UpstreamInterfaceName + " is not an interface")
+	}
+
+	imports := map[string]string{}
+	qualifier := func(pkg *types.Package) string {
+		imports[pkg.Path()] = pkg.Name()
+		return pkg.Name()
+	}
+	signatures := map[string]string{}
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		signatures[method.Name()] = strings.TrimPrefix(types.TypeString(method.Type(), qualifier), "func")
+	}
+	return signatures, imports
+}
+
+//add missing methods and update changed signatures
+func (is *interfaceSyncPipeline) update(iface *pretty_dst.InterfaceDeclaration, signatures map[string]string) {
+	names := []string{}
+	for name := range signatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		exists := iface.HasMethod(name)
+		changed, err := iface.SetMethod(name, signatures[name])
+		errors.PanicOnError(errBrokenTemplate, err)
+		if !changed {
+			continue
+		}
+		if exists {
+			is.changes = append(is.changes, "updated "+name+signatures[name])
+			continue
+		}
+		iface.GetMethod(name).SetDoc(fmt.Sprintf(syncedMethodComment, is.UpstreamPackageName, is.UpstreamInterfaceName))
+		is.changes = append(is.changes, "added "+name+signatures[name])
+	}
+}
+
+//remove methods added by previous syncs which upstream interface doesn't have anymore
+func (is *interfaceSyncPipeline) removeDropped(iface *pretty_dst.InterfaceDeclaration, signatures map[string]string) {
+	marker := fmt.Sprintf(syncedMethodComment, is.UpstreamPackageName, is.UpstreamInterfaceName)
+	for _, method := range iface.GetMethods() {
+		name := method.GetName()
+		if _, ok := signatures[name]; ok {
+			continue
+		}
+		for _, comment := range method.Decs.Start.All() {
+			if comment == marker {
+				iface.RemoveMethod(name)
+				is.changes = append(is.changes, "removed "+name)
+				break
+			}
+		}
+	}
+}
+
+//print changes of interface
+func (is *interfaceSyncPipeline) report() {
+	if len(is.changes) == 0 {
+		return
+	}
+	fmt.Printf("%s synced with %s.%s:\n", is.InterfaceName, is.UpstreamPackageName, is.UpstreamInterfaceName)
+	for _, change := range is.changes {
+		fmt.Println("\t" + change)
+	}
+}
@@ -0,0 +1,101 @@
+package olivere_v6_pipelines
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+//resolves imports from packages checked from source
+type testImporter map[string]*types.Package
+
+func (ti testImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := ti[path]; ok {
+		return pkg, nil
+	}
+	return nil, fmt.Errorf("package %s is not found", path)
+}
+
+//type-checks upstream package source
+func newTestImporter(t *testing.T, source string) testImporter {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "elastic.go", source, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{}).Check(elasticPackagePath, fileSet, []*ast.File{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testImporter{elasticPackagePath: pkg}
+}
+
+func TestInterfaceSyncPipeline(t *testing.T) {
+	upstream := "package elastic\n\ntype Meta map[string]interface{}\n\ntype Aggregation interface {\n\tSource() (interface{}, error)\n\tMeta() Meta\n}\n"
+
+	tests := []struct {
+		name     string
+		local    string
+		expected string
+	}{
+		{
+			name:     "missing methods are added with import of upstream package",
+			local:    "package aggretastic\n\ntype Aggregation interface {\n\tInjectable\n}\n",
+			expected: "package aggretastic\n\nimport (\n\t\"github.com/olivere/elastic\"\n)\n\ntype Aggregation interface {\n\tInjectable\n\t// synced from elastic.Aggregation\n\tMeta() elastic.Meta\n\t// synced from elastic.Aggregation\n\tSource() (interface{}, error)\n}\n",
+		},
+		{
+			name:     "changed signature is updated and comment is kept",
+			local:    "package aggretastic\n\nimport \"github.com/olivere/elastic\"\n\ntype Aggregation interface {\n\t//own comment\n\tSource() (map[string]interface{}, error)\n\tMeta() elastic.Meta\n}\n",
+			expected: "package aggretastic\n\nimport \"github.com/olivere/elastic\"\n\ntype Aggregation interface {\n\t//own comment\n\tSource() (interface{}, error)\n\tMeta() elastic.Meta\n}\n",
+		},
+		{
+			name:     "dropped synced methods are removed, own methods are kept",
+			local:    "package aggretastic\n\nimport \"github.com/olivere/elastic\"\n\ntype Aggregation interface {\n\t// synced from elastic.Aggregation\n\tDropped() int\n\tOwn() int\n\tSource() (interface{}, error)\n\tMeta() elastic.Meta\n}\n",
+			expected: "package aggretastic\n\nimport \"github.com/olivere/elastic\"\n\ntype Aggregation interface {\n\tOwn() int\n\tSource() (interface{}, error)\n\tMeta() elastic.Meta\n}\n",
+		},
+		{
+			name:     "embedded upstream interface is kept",
+			local:    "package aggretastic\n\nimport \"github.com/olivere/elastic\"\n\ntype Aggregation interface {\n\telastic.Aggregation\n}\n",
+			expected: "package aggretastic\n\nimport \"github.com/olivere/elastic\"\n\ntype Aggregation interface {\n\telastic.Aggregation\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := newTestFS(t, map[string]string{"aggs-interface.go": test.local})
+			syncer := interfaceSyncPipeline{
+				BuildPath:             testBuildPath,
+				Filename:              "aggs-interface.go",
+				InterfaceName:         aggregationInterfaceName,
+				UpstreamPath:          elasticPackagePath,
+				UpstreamPackageName:   elasticPackageName,
+				UpstreamInterfaceName: aggregationInterfaceName,
+				Importer:              newTestImporter(t, upstream),
+				FS:                    fs,
+			}
+			syncer.Run()
+
+			if actual := readTestFile(t, fs, testBuildPath+"aggs-interface.go"); actual != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestInterfaceSyncPipelineMissingInterface(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	syncer := interfaceSyncPipeline{
+		BuildPath:     testBuildPath,
+		Filename:      "aggs-interface.go",
+		InterfaceName: aggregationInterfaceName,
+		FS:            newTestFS(t, map[string]string{"aggs-interface.go": "package aggretastic\n\ntype Injectable interface{}\n"}),
+	}
+	syncer.Run()
+}
@@ -0,0 +1,129 @@
+package olivere_v6_pipelines
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/konovenschi/aggretastic-sync/cmd"
+	"github.com/konovenschi/aggretastic-sync/errors"
+	"github.com/konovenschi/aggretastic-sync/merge"
+	"gopkg.in/src-d/go-billy.v4"
+	"io/ioutil"
+	"os"
+)
+
+//merges generated files into project keeping hand-written changes.
+//Previous generated output is stored in CachePath under the commit hash from head lock
+type mergePipeline struct {
+	FS             billy.Filesystem
+	BuildPath      string
+	TargetPath     string
+	CachePath      string
+	Commit         string
+	PreviousCommit string
+	conflicts      []string
+	kept           []string
+}
+
+//run merge pipeline
+func (mp *mergePipeline) Run() {
+	files, err := mp.FS.ReadDir(mp.BuildPath)
+	errors.PanicOnError(errCantReadDir, err)
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		mp.mergeFile(file.Name())
+	}
+
+	mp.saveSnapshot()
+	mp.report()
+}
+
+//three-way merge between previous generated, current and new generated file
+func (mp *mergePipeline) mergeFile(name string) {
+	generated := mp.readGenerated(name)
+
+	current, err := ioutil.ReadFile(mp.TargetPath + name)
+	if err != nil {
+		mp.writeFile(name, generated)
+		return
+	}
+
+	//without previous generated output local changes can't be told apart from upstream ones,
+	//so files which differ from new output are kept as is
+	base, err := ioutil.ReadFile(mp.snapshotPath(mp.PreviousCommit) + name)
+	if mp.PreviousCommit == "" || err != nil {
+		if !bytes.Equal(current, generated) {
+			mp.kept = append(mp.kept, name)
+		}
+		return
+	}
+
+	result := merge.ThreeWay(base, current, generated, merge.Labels{
+		Ours:   name,
+		Base:   "generated@" + mp.PreviousCommit,
+		Theirs: "generated@" + mp.Commit,
+	})
+	mp.writeFile(name, result.Content)
+
+	if result.Conflicts > 0 {
+		mp.conflicts = append(mp.conflicts, fmt.Sprintf("%s: %d conflict(s)", name, result.Conflicts))
+	}
+}
+
+//read new generated file from build path
+func (mp *mergePipeline) readGenerated(name string) []byte {
+	file, err := mp.FS.Open(mp.BuildPath + name)
+	errors.PanicOnError(errCantOpenFile, err)
+	defer func() {
+		err := file.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}()
+
+	content, err := ioutil.ReadAll(file)
+	errors.PanicOnError(errCantOpenFile, err)
+	return content
+}
+
+func (mp *mergePipeline) writeFile(name string, content []byte) {
+	err := ioutil.WriteFile(mp.TargetPath+name, content, os.ModePerm)
+	errors.PanicOnError(errCantWriteFile, err)
+}
+
+//keep new generated output as base for the next sync and drop the previous one
+func (mp *mergePipeline) saveSnapshot() {
+	path := mp.snapshotPath(mp.Commit)
+	err := os.MkdirAll(path, os.ModePerm)
+	errors.PanicOnError(errCantWriteFile, err)
+
+	err = cmd.ExtractFilesFromMemory(mp.FS, ".*", mp.BuildPath, path)
+	errors.PanicOnError(errCantCopyFile, err)
+
+	if mp.PreviousCommit != "" && mp.PreviousCommit != mp.Commit {
+		err = os.RemoveAll(mp.snapshotPath(mp.PreviousCommit))
+		errors.PanicOnError(errCantRemoveFile, err)
+	}
+}
+
+func (mp *mergePipeline) snapshotPath(commit string) string {
+	return mp.CachePath + commit + "/"
+}
+
+//print files which contain conflict markers and files which have not been updated
+func (mp *mergePipeline) report() {
+	if len(mp.kept) > 0 {
+		fmt.Printf("Files differ from generated output and there is no previous output to merge with, they are kept as is.\n")
+		fmt.Printf("Compare them with %s and copy generated version if they have no local changes:\n", mp.snapshotPath(mp.Commit))
+		for _, name := range mp.kept {
+			fmt.Println("\t" + name)
+		}
+	}
+	if len(mp.conflicts) == 0 {
+		return
+	}
+	fmt.Println("Local changes conflict with upstream changes, resolve conflict markers manually:")
+	for _, conflict := range mp.conflicts {
+		fmt.Println("\t" + conflict)
+	}
+}
@@ -0,0 +1,116 @@
+package olivere_v6_pipelines
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergePipeline(t *testing.T) {
+	tests := []struct {
+		name      string
+		local     string
+		previous  string
+		generated string
+		expected  string
+		kept      []string
+		conflicts int
+	}{
+		{
+			name:      "new file is written",
+			generated: "a\nb\n",
+			expected:  "a\nb\n",
+		},
+		{
+			name:      "local changes are merged with upstream changes",
+			local:     "a\nlocal\nc\nd\n",
+			previous:  "a\nb\nc\nd\n",
+			generated: "a\nb\nc\nupstream\n",
+			expected:  "a\nlocal\nc\nupstream\n",
+		},
+		{
+			name:      "conflicting changes are marked",
+			local:     "a\nlocal\n",
+			previous:  "a\nb\n",
+			generated: "a\nupstream\n",
+			expected:  "a\n<<<<<<< aggs_x.go\nlocal\n||||||| generated@old\nb\n=======\nupstream\n>>>>>>> generated@new\n",
+			conflicts: 1,
+		},
+		{
+			name:      "file without previous output is kept",
+			local:     "a\nlocal\n",
+			generated: "a\nupstream\n",
+			expected:  "a\nlocal\n",
+			kept:      []string{"aggs_x.go"},
+		},
+		{
+			name:      "file without previous output which equals new output",
+			local:     "a\nb\n",
+			generated: "a\nb\n",
+			expected:  "a\nb\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "merger")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			target := dir + "/target/"
+			cache := dir + "/cache/"
+			if err := os.MkdirAll(target, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.local != "" {
+				writeTestFile(t, target+"aggs_x.go", test.local)
+			}
+			previousCommit := ""
+			if test.previous != "" {
+				previousCommit = "old"
+				writeTestFile(t, cache+"old/aggs_x.go", test.previous)
+			}
+
+			merger := mergePipeline{
+				FS:             newTestFS(t, map[string]string{"aggs_x.go": test.generated}),
+				BuildPath:      testBuildPath,
+				TargetPath:     target,
+				CachePath:      cache,
+				Commit:         "new",
+				PreviousCommit: previousCommit,
+			}
+			merger.Run()
+
+			content, err := ioutil.ReadFile(target + "aggs_x.go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, string(content))
+			}
+			if !reflect.DeepEqual(merger.kept, test.kept) {
+				t.Errorf("expected kept files %v, got %v", test.kept, merger.kept)
+			}
+			if len(merger.conflicts) != test.conflicts {
+				t.Errorf("expected %d conflicting files, got %v", test.conflicts, merger.conflicts)
+			}
+			snapshot, err := ioutil.ReadFile(cache + "new/aggs_x.go")
+			if err != nil || string(snapshot) != test.generated {
+				t.Errorf("new output is not stored as snapshot: %q, %v", string(snapshot), err)
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, filename string, content string) {
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, []byte(content), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+}
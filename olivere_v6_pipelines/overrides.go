@@ -0,0 +1,133 @@
+package olivere_v6_pipelines
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//separates old and new type names in AGGREGATION_RENAME entries
+const overrideRenameSeparator = ":"
+
+//allow/deny list and per-type overrides for aggregation structures
+type aggregationOverrides struct {
+	Exclude            []string
+	ForceInjectable    []string
+	ForceNotInjectable []string
+	Rename             map[string]string
+
+	decisions []overrideDecision
+	matched   map[string]bool
+}
+
+//effective decision made for single aggregation type
+type overrideDecision struct {
+	Type     string
+	File     string
+	Decision string
+}
+
+//creates overrides from env-style lists
+func newAggregationOverrides(exclude, injectable, notInjectable, rename []string) *aggregationOverrides {
+	renames := map[string]string{}
+	for _, pair := range rename {
+		parts := strings.SplitN(pair, overrideRenameSeparator, 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			panic(errBrokenOverride.Error() + pair)
+		}
+		renames[parts[0]] = parts[1]
+	}
+
+	return &aggregationOverrides{
+		Exclude:            exclude,
+		ForceInjectable:    injectable,
+		ForceNotInjectable: notInjectable,
+		Rename:             renames,
+		matched:            map[string]bool{},
+	}
+}
+
+//returns true if aggregation type should not be exported
+func (o *aggregationOverrides) isExcluded(typeName string) bool {
+	return o.lookup(o.Exclude, typeName)
+}
+
+//returns forced strategy for aggregation type or nil
+func (o *aggregationOverrides) forcedStrategy(typeName string) modificationStrategy {
+	if o.lookup(o.ForceInjectable, typeName) {
+		return injectableStrategy{}
+	}
+	if o.lookup(o.ForceNotInjectable, typeName) {
+		return notInjectableStrategy{}
+	}
+	return nil
+}
+
+//returns new name for aggregation type if any
+func (o *aggregationOverrides) renameTo(typeName string) (string, bool) {
+	name, ok := o.Rename[typeName]
+	if ok {
+		o.matched[typeName] = true
+	}
+	return name, ok
+}
+
+//register decision for report
+func (o *aggregationOverrides) decide(typeName, file, decision string) {
+	o.decisions = append(o.decisions, overrideDecision{
+		Type:     typeName,
+		File:     file,
+		Decision: decision,
+	})
+}
+
+//print effective decisions and overrides which has not matched anything
+func (o *aggregationOverrides) report() {
+	sort.SliceStable(o.decisions, func(i, j int) bool {
+		return o.decisions[i].Type < o.decisions[j].Type
+	})
+
+	fmt.Println("Aggregation decisions:")
+	for _, decision := range o.decisions {
+		fmt.Printf("\t%s (%s): %s\n", decision.Type, decision.File, decision.Decision)
+	}
+
+	unused := o.unused()
+	if len(unused) > 0 {
+		fmt.Println("Overrides which have not matched any aggregation:")
+		for _, name := range unused {
+			fmt.Println("\t" + name)
+		}
+	}
+}
+
+//returns configured type names which has not been matched during run
+func (o *aggregationOverrides) unused() []string {
+	names := []string{}
+	lists := [][]string{o.Exclude, o.ForceInjectable, o.ForceNotInjectable}
+	for _, list := range lists {
+		for _, name := range list {
+			if !o.matched[name] {
+				names = append(names, name)
+			}
+		}
+	}
+	for name := range o.Rename {
+		if !o.matched[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//check if type name is in list and mark it as matched
+func (o *aggregationOverrides) lookup(list []string, typeName string) bool {
+	for _, name := range list {
+		if name == typeName {
+			o.matched[name] = true
+			return true
+		}
+	}
+	return false
+}
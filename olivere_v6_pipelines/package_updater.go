@@ -4,25 +4,53 @@ import (
 	"fmt"
 	"github.com/konovenschi/aggretastic-sync/cmd"
 	"github.com/konovenschi/aggretastic-sync/errors"
+	"github.com/konovenschi/aggretastic-sync/pretty_dst"
 	"gopkg.in/src-d/go-billy.v4"
 )
 
-
+const (
+	aggretasticPackageName   = "aggretastic"
+	aggregationInterfaceName = "Aggregation"
+	aggregationNamePattern   = "(.*)Aggregation$"
+	elasticPackageName       = "elastic"
+	elasticPackagePath       = "github.com/olivere/elastic"
+)
 
 type packageUpdaterPipeline struct {
-	BuildPath string
-	RepoPath  string
-	Patterns  []string
-	Deps      []string
-	FS        billy.Filesystem
+	BuildPath   string
+	PatchesPath string
+	RepoPath    string
+	Patterns    []string
+	Deps        []string
+	FS          billy.Filesystem
+	Commit      string
+	Overrides   *aggregationOverrides
+
+	//identifiers qualified by type solver and copier, available after run
+	Qualifications []qualification
+
+	TypeSolverMaxPasses int
+	importer            *sourceImporter
+	copier              *declarationCopier
+
+	//aggregation types enriched by file updater with their strategies
+	enriched map[string]string
 }
 
 //run package updater pipeline
 func (up *packageUpdaterPipeline) Run() {
 	up.extractRequiredFiles()
+	up.newImporter()
 	up.enrichFiles()
 	up.extractDeps()
+	up.applyPatches()
+	up.buildIndex()
+	up.syncInterface()
+	up.copyInternals()
 	up.runTypeSolver()
+	up.cleanUp()
+	up.locateQualifications()
+	up.checkConformance()
 }
 
 //extract files from repo to build path
@@ -43,42 +71,145 @@ func (up *packageUpdaterPipeline) extractDeps() {
 
 //change every file ast and save on disk
 func (up *packageUpdaterPipeline) enrichFiles() {
-	files, err := up.FS.ReadDir(up.BuildPath)
+	pkg := pretty_dst.NewPackage(up.FS, up.BuildPath)
+	//renames resolve upstream types referenced from extracted files
+	pkg.Importer = up.importer
+	err := pkg.Load()
 	errors.PanicOnError(errCantReadDir, err)
 
+	up.enriched = map[string]string{}
 	fmt.Print("Update process:[")
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		name := file.Name()
+	for _, filename := range pkg.Filenames() {
 		fmt.Print("|")
 		fu := fileUpdatePipeline{
-			Filename:                   up.BuildPath + name,
-			DesiredPackageName:         "aggretastic",
-			TargetStructureNamePattern: "(.*)Aggregation$",
-			TargetFunctionNamePattern:  "^FuckAAHA(.*)Aggregation$",
-			FS:                         up.FS,
+			Filename:                   filename,
+			DesiredPackageName:         aggretasticPackageName,
+			TargetStructureNamePattern: aggregationNamePattern,
+			Package:                    pkg,
+			Commit:                     up.Commit,
+			Overrides:                  up.Overrides,
+			Enriched:                   up.enriched,
 		}
 		fu.Run()
 	}
 	fmt.Println("]")
+
+	err = pkg.Save()
+	errors.PanicOnError(errCantWriteFile, err)
+}
+
+//apply our own patches to generated files
+func (up *packageUpdaterPipeline) applyPatches() {
+	patcher := patchPipeline{
+		PatchesPath: up.PatchesPath,
+		BuildPath:   up.BuildPath,
+		FS:          up.FS,
+	}
+	patcher.Run()
+}
+
+//generate registry of exposed aggregation types
+func (up *packageUpdaterPipeline) buildIndex() {
+	indexer := indexPipeline{
+		BuildPath:     up.BuildPath,
+		Filename:      "aggs_index.go",
+		PackageName:   aggretasticPackageName,
+		InterfaceName: aggregationInterfaceName,
+		Enriched:      up.enriched,
+		Commit:        up.Commit,
+		FS:            up.FS,
+	}
+	indexer.Run()
+}
+
+//create importer which type-checks upstream from cloned sources. It is shared between
+//file updater, type solver and conformance check, so upstream is checked once
+func (up *packageUpdaterPipeline) newImporter() {
+	up.importer = newSourceImporter(up.FS, elasticPackagePath)
+}
+
+//add methods of upstream aggregation interface to Aggretastic interface
+func (up *packageUpdaterPipeline) syncInterface() {
+	syncer := interfaceSyncPipeline{
+		BuildPath:             up.BuildPath,
+		Filename:              "aggs-interface.go",
+		InterfaceName:         aggregationInterfaceName,
+		UpstreamPath:          elasticPackagePath,
+		UpstreamPackageName:   elasticPackageName,
+		UpstreamInterfaceName: aggregationInterfaceName,
+		Importer:              up.importer,
+		FS:                    up.FS,
+	}
+	syncer.Run()
+}
+
+//copy unexported upstream helpers used by extracted files, because they can't be qualified.
+//Copier is shared with type solver, so declarations are copied once
+func (up *packageUpdaterPipeline) copyInternals() {
+	up.copier = &declarationCopier{
+		Importer:    up.importer,
+		PackagePath: elasticPackagePath,
+		PackageName: elasticPackageName,
+		BuildPath:   up.BuildPath,
+		Filename:    "aggs_internal.go",
+		Header:      fmt.Sprintf(generatedPackageHeader, up.Commit),
+		FS:          up.FS,
+	}
+	if copied := up.copier.copyReferenced(up.Patterns); copied > 0 {
+		up.copier.save()
+		fmt.Printf("Unexported upstream declarations copied to %s: %d\n", up.copier.Filename, copied)
+	}
 }
 
 //run type solver
 func (up *packageUpdaterPipeline) runTypeSolver() {
-	fileList, err := up.FS.ReadDir(up.BuildPath)
-	errors.PanicOnError(errCantReadDir, err)
-
 	ts := typeSolverPipeline{
 		BuildPath:         up.BuildPath,
-		OriginPackageName: "elastic",
-		OriginPackagePath: "github.com/olivere/elastic",
-		FilesToCheck:      fileList,
+		OriginPackageName: elasticPackageName,
+		OriginPackagePath: elasticPackagePath,
+		ProtectedFiles:    up.Deps,
+		MaxPasses:         up.TypeSolverMaxPasses,
+		Importer:          up.importer,
+		Copier:            up.copier,
 		FS:                up.FS,
 	}
 	fmt.Print("Fix process: [")
-	for i := true; i != false; i = ts.Run() {
+	for pass := 0; pass < ts.MaxPasses && ts.Run(); pass++ {
 	}
+	ts.saveFiles()
+	up.Qualifications = append(ts.qualifications, up.copier.qualified()...)
 	fmt.Println("]")
+	ts.report()
+}
+
+//take lines of qualifications from saved files, because they shift after correction
+func (up *packageUpdaterPipeline) locateQualifications() {
+	up.Qualifications = locateQualifications(up.FS, up.BuildPath, elasticPackageName, up.Qualifications)
+}
+
+//remove imports and unexported declarations which are not used anymore
+func (up *packageUpdaterPipeline) cleanUp() {
+	cleaner := cleanupPipeline{
+		BuildPath:      up.BuildPath,
+		PackageName:    aggretasticPackageName,
+		ProtectedFiles: up.Deps,
+		Importer:       up.importer,
+		FS:             up.FS,
+	}
+	cleaner.Run()
+}
+
+//verify that aggregations implement Aggretastic interface and generate assertions
+func (up *packageUpdaterPipeline) checkConformance() {
+	checker := conformancePipeline{
+		BuildPath:     up.BuildPath,
+		Filename:      "aggs_assertions.go",
+		PackageName:   aggretasticPackageName,
+		InterfaceName: aggregationInterfaceName,
+		Enriched:      up.enriched,
+		Commit:        up.Commit,
+		FS:            up.FS,
+		Importer:      up.importer,
+	}
+	checker.Run()
 }
@@ -0,0 +1,88 @@
+package olivere_v6_pipelines
+
+import (
+	"fmt"
+	"github.com/konovenschi/aggretastic-sync/cmd"
+	"github.com/konovenschi/aggretastic-sync/errors"
+	"github.com/konovenschi/aggretastic-sync/patch"
+	"gopkg.in/src-d/go-billy.v4"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//applies our own unified diffs from PatchesPath to generated files
+type patchPipeline struct {
+	PatchesPath string
+	BuildPath   string
+	FS          billy.Filesystem
+}
+
+//run patch pipeline. Patches are applied in alphabetical order
+func (pp *patchPipeline) Run() {
+	patches, err := cmd.LsDiskByPattern(pp.PatchesPath, `\.(patch|diff)$`)
+	if os.IsNotExist(err) {
+		return
+	}
+	errors.PanicOnError(errCantReadDir, err)
+
+	for _, file := range patches {
+		pp.applyPatch(file.Name())
+	}
+}
+
+//apply every file diff from patch file to build path
+func (pp *patchPipeline) applyPatch(name string) {
+	content, err := ioutil.ReadFile(filepath.Join(pp.PatchesPath, name))
+	errors.PanicOnError(errCantOpenFile, err)
+
+	diffs, err := patch.Parse(content)
+	errors.PanicOnError(fmt.Errorf("%s%s: ", errCantApplyPatch.Error(), name), err)
+
+	for _, diff := range diffs {
+		pp.applyFileDiff(name, diff)
+	}
+	fmt.Println("Patch has been applied: " + name)
+}
+
+func (pp *patchPipeline) applyFileDiff(patchName string, diff patch.FileDiff) {
+	errPatch := fmt.Errorf("%s%s: ", errCantApplyPatch.Error(), patchName)
+
+	if diff.NewName == patch.DevNull {
+		err := pp.FS.Remove(pp.BuildPath + filepath.Base(diff.OldName))
+		errors.PanicOnError(errPatch, err)
+		return
+	}
+
+	filename := pp.BuildPath + filepath.Base(diff.NewName)
+	content := []byte{}
+	if diff.OldName != patch.DevNull {
+		content = pp.readFile(filename, errPatch)
+	}
+
+	patched, err := diff.Apply(content)
+	errors.PanicOnError(errPatch, err)
+
+	file, err := pp.FS.Create(filename)
+	errors.PanicOnError(errCantOpenFile, err)
+	defer func() {
+		err := file.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}()
+
+	_, err = file.Write(patched)
+	errors.PanicOnError(errCantWriteFile, err)
+}
+
+func (pp *patchPipeline) readFile(filename string, errPatch error) []byte {
+	file, err := pp.FS.Open(filename)
+	errors.PanicOnError(errPatch, err)
+	defer func() {
+		err := file.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}()
+
+	content, err := ioutil.ReadAll(file)
+	errors.PanicOnError(errCantOpenFile, err)
+	return content
+}
@@ -8,6 +8,7 @@ import (
 	"gopkg.in/src-d/go-billy.v4"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -27,6 +28,14 @@ var (
 	errCantCreateLock = fmt.Errorf("Can't create lock file: ")
 	errBrokenStorage  = fmt.Errorf("Repository storage is broken: ")
 
+	errCantApplyPatch = fmt.Errorf("Patch can't be applied: ")
+	errTypeCheck      = fmt.Errorf("Build package has type errors: ")
+	errNonConforming  = fmt.Errorf("Aggregations don't implement Aggretastic interface: ")
+	errBrokenOverride = fmt.Errorf("Aggregation rename override should look like Old:New, got: ")
+	errBrokenTemplate = fmt.Errorf("Code template can't be parsed: ")
+	errBrokenPasses   = fmt.Errorf("TYPE_SOLVER_MAX_PASSES should be positive, got: ")
+	errMissingImport  = fmt.Errorf("Imported package is not available: ")
+
 )
 
 type olivere_v6_vars struct {
@@ -36,8 +45,17 @@ type olivere_v6_vars struct {
 	repoBranch   string
 
 	buildPath             string
+	cachePath             string
+	patchesPath           string
+	qualificationReport   string
+	typeSolverMaxPasses   int
 	elasticExportPatterns []string
 	deps                  []string
+
+	excludeAggregations       []string
+	injectableAggregations    []string
+	notInjectableAggregations []string
+	renameAggregations        []string
 }
 
 //load required variables from env
@@ -47,16 +65,58 @@ func loadVars() olivere_v6_vars {
 	if files == "" {
 		panic("This pipeline requires aaha aggregation types. Please, specify them in Conf.env file. ")
 	}
+	cachePath := os.Getenv("GENERATED_CACHE_PATH")
+	if cachePath == "" {
+		cachePath = ".aggretastic-sync/"
+	}
+	patchesPath := os.Getenv("PATCHES_PATH")
+	if patchesPath == "" {
+		patchesPath = "patches/"
+	}
+	qualificationReport := os.Getenv("QUALIFICATION_REPORT")
+	if qualificationReport == "" {
+		qualificationReport = "qualifications"
+	}
+	maxPasses := 50
+	if value := os.Getenv("TYPE_SOLVER_MAX_PASSES"); value != "" {
+		var err error
+		maxPasses, err = strconv.Atoi(value)
+		errors.PanicOnError(errCantAtoi, err)
+		if maxPasses < 1 {
+			panic(errBrokenPasses.Error() + value)
+		}
+	}
 	return olivere_v6_vars{
 		repo: os.Getenv("ELASTIC_REPO"),
 		repoHeadLock: os.Getenv("HEAD_LOCK_FILE"),
 		repoBranch:   os.Getenv("REPO_BRANCH"),
 		buildPath:             "build-tmp/",
+		cachePath:             cachePath,
+		patchesPath:           patchesPath,
+		qualificationReport:   qualificationReport,
+		typeSolverMaxPasses:   maxPasses,
 		elasticExportPatterns: strings.Split(patterns, ", "),
 		deps:                  strings.Split(files, ", "),
+
+		excludeAggregations:       splitList(os.Getenv("AGGREGATION_EXCLUDE")),
+		injectableAggregations:    splitList(os.Getenv("AGGREGATION_FORCE_INJECTABLE")),
+		notInjectableAggregations: splitList(os.Getenv("AGGREGATION_FORCE_NOT_INJECTABLE")),
+		renameAggregations:        splitList(os.Getenv("AGGREGATION_RENAME")),
 	}
 }
 
+//split comma-separated env list. Empty value produces empty list
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 //run olivere_v6 pipeline
 func Run() {
 	defer func() {
@@ -73,28 +133,57 @@ func Run() {
 		Branch: vars.repoBranch,
 		Lock:   vars.repoHeadLock,
 	}
-	isUpToDate, fs := git.Run()
+	previousCommit := git.previousCommit()
+	isUpToDate, fs, commit := git.Run()
 
 	if isUpToDate {
 		log.Println("Aggretastic is already up-to-date with " + vars.repoBranch)
 		return
 	}
 
+	overrides := newAggregationOverrides(
+		vars.excludeAggregations,
+		vars.injectableAggregations,
+		vars.notInjectableAggregations,
+		vars.renameAggregations,
+	)
+
 	//run package updater
 	updater := packageUpdaterPipeline{
-		RepoPath:  vars.repoPath,
-		Patterns:  vars.elasticExportPatterns,
-		Deps:      vars.deps,
-		FS:        fs,
-		BuildPath: vars.buildPath,
+		RepoPath:    vars.repoPath,
+		Patterns:    vars.elasticExportPatterns,
+		Deps:        vars.deps,
+		FS:          fs,
+		BuildPath:   vars.buildPath,
+		PatchesPath: vars.patchesPath,
+		Commit:      commit,
+		Overrides:   overrides,
+
+		TypeSolverMaxPasses: vars.typeSolverMaxPasses,
 	}
 	updater.Run()
+	overrides.report()
 
-	buildPackage(fs, vars.buildPath)
+	audit := auditPipeline{
+		ReportPath:     vars.qualificationReport,
+		Commit:         commit,
+		Qualifications: updater.Qualifications,
+	}
+	audit.Run()
+
+	merger := mergePipeline{
+		FS:             fs,
+		BuildPath:      vars.buildPath,
+		TargetPath:     "./",
+		CachePath:      vars.cachePath,
+		Commit:         commit,
+		PreviousCommit: previousCommit,
+	}
+	buildPackage(fs, vars.buildPath, &merger)
 }
 
-//copy updater artifacts in main repository and remove deprecated
-func buildPackage(fs billy.Filesystem, buildPath string) {
+//merge updater artifacts in main repository and remove deprecated
+func buildPackage(fs billy.Filesystem, buildPath string, merger *mergePipeline) {
 	originFileList, err := cmd.LsDiskByPattern("./", "^aggs_(.*).go$")
 	errors.PanicOnError(errCantReadDir, err)
 
@@ -103,9 +192,8 @@ func buildPackage(fs billy.Filesystem, buildPath string) {
 
 	deprecated := cmd.ListDiff(originFileList, buildFileList)
 
-	//copy new files to project
-	err = cmd.ExtractFilesFromMemory(fs, ".*", buildPath, "./")
-	errors.PanicOnError(errCantCopyFile, err)
+	//merge new files into project
+	merger.Run()
 
 	//remove deprecated files
 	err = cmd.RmListFromDisk("./", deprecated)
@@ -2,140 +2,310 @@ package olivere_v6_pipelines
 
 import (
 	"fmt"
+	"github.com/dave/dst/decorator"
 	"github.com/konovenschi/aggretastic-sync/errors"
+	"github.com/konovenschi/aggretastic-sync/pretty_dst"
 	"go/ast"
-	"go/importer"
-	"go/parser"
 	"go/token"
 	"go/types"
 	"gopkg.in/src-d/go-billy.v4"
-	"os"
+	"io/ioutil"
+	"sort"
+	"strings"
 )
 
 type typeSolverPipeline struct {
 	BuildPath         string
 	OriginPackagePath string
 	OriginPackageName string
-	FilesToCheck      []os.FileInfo
+	ProtectedFiles    []string
+	MaxPasses         int
+	Importer          types.Importer
+	Copier            *declarationCopier
+	pkg               *pretty_dst.Package
+	restorer          *decorator.Restorer
 	typesInfo         types.Info
 	typesConfig       *types.Config
 	astSet            []*ast.File
 	fileSet           *token.FileSet
 	FS                billy.Filesystem
+	errs              []types.Error
+	attempted         map[string]bool
+	fixed             map[string][]string
+	qualifications    []qualification
+	upstream          *types.Package
 }
 
-//run type solver pipeline
+//run type solver pipeline. Returns true if errors were found and corrected
 func (ts *typeSolverPipeline) Run() bool {
-	ts.parseFiles()
+	ts.loadFiles()
+	ts.restoreFiles()
 	ts.newTypesInfo()
 	ts.newTypesConfig()
 
-	//run error correction pipeline on error
-	err := ts.check()
-	if err != nil {
+	//collect all errors in single pass and fix them file by file.
+	//Stop if there are no errors at positions which has not been corrected yet
+	ts.check()
+	errs := ts.newFixableErrors()
+	if len(errs) == 0 {
+		return false
+	}
+
+	rewritten := ts.copyDeclarations(errs)
+
+	byFile := groupErrorsByFile(errs)
+	//rewritten file is loaded again on next pass, so its errors are corrected then
+	if rewritten != "" {
+		ts.postpone(byFile[rewritten])
+		delete(byFile, rewritten)
+	}
+	filenames := []string{}
+	for filename := range byFile {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
 		fmt.Print("|")
 		ec := errorCorrectionPipeline{
-			Err:               err.Error(),
+			Filename:          filename,
+			Errs:              byFile[filename],
 			OriginPackagePath: ts.OriginPackagePath,
 			OriginPackageName: ts.OriginPackageName,
-			FS:                ts.FS,
+			Source:            ts.pkg.File(filename),
+			Nodes:             ts.restorer.Dst.Nodes,
+			Upstream:          ts.upstream,
+			Info:              &ts.typesInfo,
+			fileSet:           ts.fileSet,
+			ast:               ts.findFile(filename),
 		}
 		ec.Run()
-		return true
+		ts.qualifications = append(ts.qualifications, ec.qualifications...)
 	}
-	return false
+	return true
 }
 
-//parse ast from source file
-func (ts *typeSolverPipeline) parseFiles() {
-	ts.astSet = []*ast.File{}
-	ts.fileSet = token.NewFileSet()
-
-	for _, file := range ts.FilesToCheck {
-		if !file.IsDir() {
-			name := ts.BuildPath + file.Name()
-			f, err := ts.FS.Open(name)
-			errors.PanicOnError(errCantOpenFile, err)
-
-			astFile, err := parser.ParseFile(ts.fileSet, name, f, parser.ParseComments)
+//copy upstream declarations which can't be qualified into generated file and drop copied methods
+//which are declared in build package. Returns path of rewritten file or empty string if file wasn't changed
+func (ts *typeSolverPipeline) copyDeclarations(errs []classifiedError) string {
+	copied := false
+	for _, err := range errs {
+		switch err.Fix {
+		case fixCopyDeclaration:
+			copied = ts.Copier.copy(err.Name) || copied
+		case fixDropMethod:
+			copied = ts.Copier.drop(err.Name) || copied
+		}
+	}
+	if !copied {
+		return ""
+	}
+	//copier rewrites whole file, so it is loaded again on next pass
+	filename := ts.BuildPath + ts.Copier.Filename
+	ts.Copier.save()
+	ts.pkg.Unload(filename)
+	return filename
+}
 
-			errors.PanicOnError(errCantParseFile, err)
-			ts.astSet = append(ts.astSet, astFile)
+//forget errors which were not corrected in this pass, so they are fixable on next pass.
+//Errors handled by copier are corrected already
+func (ts *typeSolverPipeline) postpone(errs []classifiedError) {
+	for _, err := range errs {
+		if err.Fix == fixCopyDeclaration || err.Fix == fixDropMethod {
+			continue
+		}
+		key := attemptKey(err)
+		delete(ts.attempted, key)
+		fixed := ts.fixed[err.Fix]
+		for i, description := range fixed {
+			if description == key {
+				ts.fixed[err.Fix] = append(fixed[:i], fixed[i+1:]...)
+				break
+			}
 		}
 	}
 }
 
-//run type checker
-func (ts *typeSolverPipeline) check() error {
-	_, err := ts.typesConfig.Check("", ts.fileSet, ts.astSet, &ts.typesInfo)
-	return err
+//load dst of source files which are not in memory yet. Build path is read on every pass,
+//so generated files are checked too. Loaded files are kept in memory until they are saved
+func (ts *typeSolverPipeline) loadFiles() {
+	if ts.pkg == nil {
+		ts.pkg = pretty_dst.NewPackage(ts.FS, ts.BuildPath)
+	}
+	err := ts.pkg.Load()
+	errors.PanicOnError(errCantReadDir, err)
 }
 
-func (ts *typeSolverPipeline) newTypesInfo() {
-	ts.typesInfo = types.Info{
-		Defs: make(map[*ast.Ident]types.Object),
-		Uses: make(map[*ast.Ident]types.Object),
+//restore ast of all sources into single fileset for type check
+func (ts *typeSolverPipeline) restoreFiles() {
+	restorer, files := ts.pkg.Restore()
+	ts.restorer = restorer
+	ts.fileSet = restorer.Fset
+	ts.astSet = []*ast.File{}
+	for _, filename := range ts.pkg.Filenames() {
+		ts.astSet = append(ts.astSet, files[filename])
 	}
 }
 
-func (ts *typeSolverPipeline) newTypesConfig() {
-	ts.typesConfig = &types.Config{
-		Importer: importer.Default(),
+//save changed files on disk
+func (ts *typeSolverPipeline) saveFiles() {
+	err := ts.pkg.Save()
+	errors.PanicOnError(errCantWriteFile, err)
+}
+
+//returns parsed file by name, so corrections use positions and type information of last check
+func (ts *typeSolverPipeline) findFile(filename string) *ast.File {
+	for _, file := range ts.astSet {
+		if ts.fileSet.Position(file.Pos()).Filename == filename {
+			return file
+		}
 	}
+	return nil
 }
-/*
-func lookup (path string) (io.ReadCloser, error) {
 
+//run type checker and collect all errors
+func (ts *typeSolverPipeline) check() {
+	ts.errs = []types.Error{}
+	_, _ = ts.typesConfig.Check("", ts.fileSet, ts.astSet, &ts.typesInfo)
 }
 
-func Import(packages map[string]*types.Package, path, srcDir string, lookup func(path string) (io.ReadCloser, error)) (pkg *types.Package, err error) {
-	var rc io.ReadCloser
-	var id string
-	if lookup != nil {
-		// With custom lookup specified, assume that caller has
-		// converted path to a canonical import path for use in the map.
-		if path == "unsafe" {
-			return types.Unsafe, nil
+//returns errors which can be fixed by one of fixers and mark them as attempted.
+//Error which has already been corrected at the same position can't be fixed. Message is a part
+//of the key, because positions move between passes and other error can appear at the same place
+func (ts *typeSolverPipeline) newFixableErrors() []classifiedError {
+	if ts.attempted == nil {
+		ts.attempted = map[string]bool{}
+		ts.fixed = map[string][]string{}
+	}
+
+	ts.upstream, _ = ts.Importer.Import(ts.OriginPackagePath)
+	classifier := errorClassifier{
+		Upstream:       ts.upstream,
+		ProtectedFiles: ts.ProtectedFiles,
+		Files:          ts.astSet,
+	}
+
+	fixable := []classifiedError{}
+	for _, err := range classifier.classify(ts.errs) {
+		key := attemptKey(err)
+		if ts.attempted[key] {
+			continue
 		}
-		id = path
+		ts.attempted[key] = true
+		ts.fixed[err.Fix] = append(ts.fixed[err.Fix], key)
+		fixable = append(fixable, err)
+	}
+	return fixable
+}
 
-		// No need to re-import if the package was imported completely before.
-		if pkg = packages[id]; pkg != nil && pkg.Complete() {
-			return
+//identifies error in attempted corrections and report of applied fixes
+func attemptKey(err classifiedError) string {
+	return fmt.Sprintf("%s: %s", err.Fset.Position(err.Pos), err.Msg)
+}
+
+//print applied fixes and type errors which are left after correction with source context
+func (ts *typeSolverPipeline) report() {
+	for _, fix := range []string{fixQualify, fixCopyDeclaration, fixDropMethod, fixRemoveDuplicate, fixDropImport} {
+		if len(ts.fixed[fix]) == 0 {
+			continue
 		}
-		f, err := lookup(path)
-		if err != nil {
-			return nil, err
+		fmt.Printf("Fixer %q (%d):\n", fix, len(ts.fixed[fix]))
+		for _, fixed := range ts.fixed[fix] {
+			fmt.Println("\t" + fixed)
 		}
-		rc = f
-	} else {
-		var filename string
-		filename, id = FindPkg(path, srcDir)
-		if filename == "" {
-			if path == "unsafe" {
-				return types.Unsafe, nil
-			}
-			return nil, fmt.Errorf("can't find import: %q", id)
+	}
+	if ts.Copier != nil && len(ts.Copier.copiedNames()) > 0 {
+		fmt.Printf("Declarations copied to %s: %s\n", ts.Copier.Filename, strings.Join(ts.Copier.copiedNames(), ", "))
+	}
+
+	//saved files are loaded again, so positions match lines on disk
+	ts.pkg = nil
+	ts.loadFiles()
+	ts.restoreFiles()
+	ts.newTypesInfo()
+	ts.newTypesConfig()
+	ts.check()
+	if len(ts.errs) == 0 {
+		return
+	}
+
+	fmt.Printf("Type errors which can't be fixed automatically (%d):\n", len(ts.errs))
+	sources := map[string][]string{}
+	for _, err := range ts.errs {
+		position := err.Fset.Position(err.Pos)
+		if _, ok := sources[position.Filename]; !ok {
+			sources[position.Filename] = ts.readLines(position.Filename)
 		}
+		fmt.Println(err.Error())
+		fmt.Print(sourceContext(sources[position.Filename], position))
+	}
+}
+
+//read file lines from build path
+func (ts *typeSolverPipeline) readLines(filename string) []string {
+	file, err := ts.FS.Open(filename)
+	errors.PanicOnError(errCantOpenFile, err)
+	defer func() {
+		err := file.Close()
+		errors.PanicOnError(errCantCloseFile, err)
+	}()
+
+	content, err := ioutil.ReadAll(file)
+	errors.PanicOnError(errCantOpenFile, err)
+	return strings.Split(string(content), "\n")
+}
+
+//returns source line with caret pointing to error column
+func sourceContext(lines []string, position token.Position) string {
+	if position.Line < 1 || position.Line > len(lines) {
+		return ""
+	}
+	line := lines[position.Line-1]
 
-		// no need to re-import if the package was imported completely before
-		if pkg = packages[id]; pkg != nil && pkg.Complete() {
-			return
+	caret := []byte{}
+	for i := 0; i < position.Column-1 && i < len(line); i++ {
+		//keep tabs, so caret is aligned with source line
+		if line[i] == '\t' {
+			caret = append(caret, '\t')
+		} else {
+			caret = append(caret, ' ')
 		}
+	}
+	return fmt.Sprintf("\t%s\n\t%s^\n", line, caret)
+}
 
-		// open file
-		f, err := os.Open(filename)
-		if err != nil {
-			return nil, err
+//group errors by file name
+func groupErrorsByFile(errs []classifiedError) map[string][]classifiedError {
+	byFile := map[string][]classifiedError{}
+	for _, err := range errs {
+		if err.Fix == fixCopyDeclaration {
+			continue
 		}
-		defer func() {
-			if err != nil {
-				// add file name to error
-				err = fmt.Errorf("%s: %v", filename, err)
-			}
-		}()
-		rc = f
+		filename := err.Fset.Position(err.Pos).Filename
+		byFile[filename] = append(byFile[filename], err)
 	}
+	return byFile
+}
 
+func (ts *typeSolverPipeline) newTypesInfo() {
+	ts.typesInfo = types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+}
+
+//config is created once, so imported packages are cached between passes
+func (ts *typeSolverPipeline) newTypesConfig() {
+	if ts.typesConfig != nil {
+		return
+	}
+	ts.typesConfig = &types.Config{
+		Importer: ts.Importer,
+		Error: func(err error) {
+			if typeErr, ok := err.(types.Error); ok {
+				ts.errs = append(ts.errs, typeErr)
+			}
+		},
+	}
 }
-*/
\ No newline at end of file
@@ -0,0 +1,217 @@
+//Package patch parses and applies unified diffs.
+package patch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//name used in diffs for absent file
+const DevNull = "/dev/null"
+
+//hunk is searched at most this number of lines away from position in its header
+const searchWindow = 100
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+//Changes of single file
+type FileDiff struct {
+	OldName string
+	NewName string
+	Hunks   []Hunk
+}
+
+//Single @@ section of file diff
+type Hunk struct {
+	Header   string
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string
+}
+
+//Parse unified diff which may contain changes of several files
+func Parse(diff []byte) ([]FileDiff, error) {
+	files := []FileDiff{}
+	lines := strings.Split(string(diff), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			files = append(files, FileDiff{OldName: parseName(line)})
+		case strings.HasPrefix(line, "+++ "):
+			if len(files) == 0 {
+				return nil, fmt.Errorf("line %d: +++ without ---", i+1)
+			}
+			files[len(files)-1].NewName = parseName(line)
+		case strings.HasPrefix(line, "@@"):
+			if len(files) == 0 {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			file := &files[len(files)-1]
+			file.Hunks = append(file.Hunks, hunk)
+			i = next - 1
+		}
+	}
+	return files, nil
+}
+
+//returns file name from ---/+++ line without a/ b/ prefixes and timestamps
+func parseName(line string) string {
+	name := strings.TrimSpace(line[4:])
+	if index := strings.IndexByte(name, '\t'); index >= 0 {
+		name = name[:index]
+	}
+	if name == DevNull {
+		return name
+	}
+	if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+		name = name[2:]
+	}
+	return name
+}
+
+//parse hunk starting from header line. Returns hunk and index of first line after it
+func parseHunk(lines []string, start int) (Hunk, int, error) {
+	match := hunkHeader.FindStringSubmatch(lines[start])
+	if match == nil {
+		return Hunk{}, 0, fmt.Errorf("line %d: broken hunk header %q", start+1, lines[start])
+	}
+	hunk := Hunk{
+		Header:   lines[start],
+		OldStart: atoi(match[1], 0),
+		OldLines: atoi(match[2], 1),
+		NewStart: atoi(match[3], 0),
+		NewLines: atoi(match[4], 1),
+	}
+
+	oldCount, newCount := 0, 0
+	i := start + 1
+	for ; i < len(lines) && (oldCount < hunk.OldLines || newCount < hunk.NewLines); i++ {
+		line := lines[i]
+		if line == "" {
+			//some editors strip trailing space of empty context lines
+			line = " "
+		}
+		switch line[0] {
+		case ' ':
+			oldCount++
+			newCount++
+		case '-':
+			oldCount++
+		case '+':
+			newCount++
+		case '\\':
+			continue
+		default:
+			return Hunk{}, 0, fmt.Errorf("line %d: unexpected line in hunk %q", i+1, lines[i])
+		}
+		hunk.Lines = append(hunk.Lines, line)
+	}
+	if oldCount != hunk.OldLines || newCount != hunk.NewLines {
+		return Hunk{}, 0, fmt.Errorf("hunk %q is truncated", hunk.Header)
+	}
+	//skip "\ No newline at end of file" marker after the last line
+	if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+		i++
+	}
+	return hunk, i, nil
+}
+
+func atoi(value string, fallback int) int {
+	if value == "" {
+		return fallback
+	}
+	number, _ := strconv.Atoi(value)
+	return number
+}
+
+//returns lines which hunk expects to find and lines which it produces
+func (h Hunk) split() (before []string, after []string) {
+	for _, line := range h.Lines {
+		switch line[0] {
+		case ' ':
+			before = append(before, line[1:])
+			after = append(after, line[1:])
+		case '-':
+			before = append(before, line[1:])
+		case '+':
+			after = append(after, line[1:])
+		}
+	}
+	return before, after
+}
+
+//Applies all hunks to content. Hunks are searched near expected position (within searchWindow lines),
+//so line shifts are allowed but changed context or two equally close matches make patch fail
+func (fd FileDiff) Apply(content []byte) ([]byte, error) {
+	lines := strings.Split(string(content), "\n")
+	offset := 0
+	for _, hunk := range fd.Hunks {
+		before, after := hunk.split()
+		expected := hunk.OldStart - 1 + offset
+		if hunk.OldLines == 0 {
+			expected = hunk.OldStart + offset
+		}
+
+		position, err := find(lines, before, expected)
+		if err != nil {
+			return nil, fmt.Errorf("hunk %s of %s %s", hunk.Header, fd.NewName, err)
+		}
+
+		patched := append([]string{}, lines[:position]...)
+		patched = append(patched, after...)
+		patched = append(patched, lines[position+len(before):]...)
+		lines = patched
+
+		offset = position - (expected - offset) + len(after) - len(before)
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+//find block position closest to expected one within search window.
+//Matches at the same distance before and after expected position are ambiguous
+func find(lines []string, block []string, expected int) (int, error) {
+	if expected < 0 {
+		expected = 0
+	}
+	if expected > len(lines) {
+		expected = len(lines)
+	}
+	if len(block) == 0 {
+		return expected, nil
+	}
+
+	for distance := 0; distance <= searchWindow; distance++ {
+		found := []int{}
+		for _, position := range []int{expected - distance, expected + distance} {
+			if position >= 0 && position+len(block) <= len(lines) && matches(lines[position:], block) {
+				found = append(found, position)
+			}
+		}
+		switch {
+		case len(found) == 1 || distance == 0 && len(found) > 0:
+			return found[0], nil
+		case len(found) > 1:
+			return -1, fmt.Errorf("is ambiguous: it matches lines %d and %d", found[0]+1, found[1]+1)
+		}
+	}
+	return -1, fmt.Errorf("does not apply")
+}
+
+func matches(lines []string, block []string) bool {
+	for i := range block {
+		if lines[i] != block[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package patch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		expected []FileDiff
+	}{
+		{
+			name: "single hunk",
+			diff: "--- a/x.go\n+++ b/x.go\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			expected: []FileDiff{{
+				OldName: "x.go",
+				NewName: "x.go",
+				Hunks: []Hunk{{
+					Header:   "@@ -1,2 +1,2 @@",
+					OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2,
+					Lines: []string{" a", "-b", "+c"},
+				}},
+			}},
+		},
+		{
+			name: "new file with timestamps and omitted counts",
+			diff: "--- /dev/null\t2019-01-01 00:00:00\n+++ b/y.go\t2019-01-01 00:00:00\n@@ -0,0 +1 @@\n+a\n",
+			expected: []FileDiff{{
+				OldName: DevNull,
+				NewName: "y.go",
+				Hunks: []Hunk{{
+					Header:   "@@ -0,0 +1 @@",
+					OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 1,
+					Lines: []string{"+a"},
+				}},
+			}},
+		},
+		{
+			name: "several files, stripped empty context line and no newline marker",
+			diff: "--- a/x.go\n+++ b/x.go\n@@ -1,2 +1,2 @@\n-a\n+b\n\n\\ No newline at end of file\n--- a/y.go\n+++ b/y.go\n@@ -3 +3 @@\n-c\n+d\n",
+			expected: []FileDiff{
+				{
+					OldName: "x.go",
+					NewName: "x.go",
+					Hunks: []Hunk{{
+						Header:   "@@ -1,2 +1,2 @@",
+						OldStart: 1, OldLines: 2, NewStart: 1, NewLines: 2,
+						Lines: []string{"-a", "+b", " "},
+					}},
+				},
+				{
+					OldName: "y.go",
+					NewName: "y.go",
+					Hunks: []Hunk{{
+						Header:   "@@ -3 +3 @@",
+						OldStart: 3, OldLines: 1, NewStart: 3, NewLines: 1,
+						Lines: []string{"-c", "+d"},
+					}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := Parse([]byte(test.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, files)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+	}{
+		{"new name without old one", "+++ b/x.go\n"},
+		{"hunk without file header", "@@ -1 +1 @@\n-a\n+b\n"},
+		{"broken hunk header", "--- a/x.go\n+++ b/x.go\n@@ -a +b @@\n"},
+		{"truncated hunk", "--- a/x.go\n+++ b/x.go\n@@ -1,3 +1,3 @@\n a\n"},
+		{"unexpected line", "--- a/x.go\n+++ b/x.go\n@@ -1,2 +1,2 @@\n a\n*b\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Parse([]byte(test.diff)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestFileDiffApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "exact position",
+			diff:     "--- a/x.go\n+++ b/x.go\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+			content:  "a\nb\nc\nd\ne",
+			expected: "a\nb\nC\nd\ne",
+		},
+		{
+			name:     "shifted lines",
+			diff:     "--- a/x.go\n+++ b/x.go\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+			content:  "new\nnew\na\nb\nc\nd\ne",
+			expected: "new\nnew\na\nb\nC\nd\ne",
+		},
+		{
+			name:     "offset of previous hunk is applied to next one",
+			diff:     "--- a/x.go\n+++ b/x.go\n@@ -1,2 +1,4 @@\n a\n+a1\n+a2\n b\n@@ -4,2 +6,2 @@\n d\n-e\n+E\n",
+			content:  "a\nb\nc\nd\ne",
+			expected: "a\na1\na2\nb\nc\nd\nE",
+		},
+		{
+			name:     "insertion into empty file",
+			diff:     "--- /dev/null\n+++ b/x.go\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			content:  "",
+			expected: "a\nb\n",
+		},
+		{
+			name:     "closest match wins",
+			diff:     "--- a/x.go\n+++ b/x.go\n@@ -5,1 +5,1 @@\n-}\n+} //end\n",
+			content:  "}\na\nb\nc\n}\nd\ne\nf\ng\nh\n}",
+			expected: "}\na\nb\nc\n} //end\nd\ne\nf\ng\nh\n}",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := Parse([]byte(test.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			patched, err := files[0].Apply([]byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(patched) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, string(patched))
+			}
+		})
+	}
+}
+
+func TestFileDiffApplyErrors(t *testing.T) {
+	farAway := append(make([]string, searchWindow+10), "a", "b")
+
+	tests := []struct {
+		name    string
+		diff    string
+		content string
+	}{
+		{
+			name:    "changed context",
+			diff:    "--- a/x.go\n+++ b/x.go\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			content: "a\nc",
+		},
+		{
+			name:    "equally close matches",
+			diff:    "--- a/x.go\n+++ b/x.go\n@@ -3,1 +3,1 @@\n-}\n+} //end\n",
+			content: "a\n}\nb\n}\nc",
+		},
+		{
+			name:    "match outside of search window",
+			diff:    "--- a/x.go\n+++ b/x.go\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			content: strings.Join(farAway, "\n"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files, err := Parse([]byte(test.diff))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := files[0].Apply([]byte(test.content)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -37,6 +37,14 @@ func (f *Function) Rename(newName string) {
 	f.name.Name = newName
 }
 
+//returns receiver type name without pointer or empty string for plain functions
+func (f *Function) GetReceiverType() string {
+	if f.origin.Recv == nil || len(f.origin.Recv.List) == 0 {
+		return ""
+	}
+	return typeName(f.origin.Recv.List[0].Type)
+}
+
 //returns reference to this function body
 func (f *Function) GetBody() *FunctionBody {
 	return f.body
@@ -85,6 +93,11 @@ func (body *FunctionBody) AppendNewAssigment(name string, operator token.Token,
 	body.List = append(body.List, assigment)
 }
 
+//appends statements to the function body
+func (body *FunctionBody) Append(statements ...dst.Stmt) {
+	body.List = append(body.List, statements...)
+}
+
 //appends new return statement to the function body
 func (body *FunctionBody) AppendNewReturn(args ...dst.Expr) {
 	assigment := NewReturnStatement(args...)
@@ -126,10 +139,42 @@ func (s *StructureDeclaration) Rename(newName string) {
 	s.name.Name = newName
 }
 
-//declares new field in structure
-func (s *StructureDeclaration) AddField(name string, _type string) {
-	field := NewDataField(name, _type)
+//declares new field in structure. Type is Go source of type expression (map[string]interface{})
+func (s *StructureDeclaration) AddField(name string, _type string) *Field {
+	return s.InsertField(NewDataField(name, _type))
+}
+
+//declares new embedded field in structure (*Injectable, elastic.Script)
+func (s *StructureDeclaration) AddEmbeddedField(_type string) *Field {
+	expr, err := NewTypeExpression(_type)
+	if err != nil {
+		expr = NewIdent(_type, nil)
+	}
+	return s.InsertField(NewEmbeddedField(expr))
+}
+
+//appends prepared field to structure
+func (s *StructureDeclaration) InsertField(field *dst.Field) *Field {
 	s.fields.List = append(s.fields.List, field)
+	return DecorateField(field)
+}
+
+//returns field by name (embedded fields by type name) or nil
+func (s *StructureDeclaration) GetField(name string) *Field {
+	_, field := findField(s.fields, name)
+	if field == nil {
+		return nil
+	}
+	return DecorateField(field)
+}
+
+//returns all fields in declaration order
+func (s *StructureDeclaration) GetFields() []*Field {
+	fields := []*Field{}
+	for _, field := range s.fields.List {
+		fields = append(fields, DecorateField(field))
+	}
+	return fields
 }
 
 //removes field from structure by name
@@ -142,9 +187,22 @@ func (s *StructureDeclaration) RemoveField(name string) error {
 	return nil
 }
 
-//find field position in FieldList
+//Checks if structure embeds type with such name (as value or pointer)
+func (s *StructureDeclaration) IsEmbedded(name string) bool {
+	for _, field := range s.fields.List {
+		if len(field.Names) == 0 && typeName(field.Type) == name {
+			return true
+		}
+	}
+	return false
+}
+
+//find field position in FieldList. Embedded fields are found by type name
 func findField(fields *dst.FieldList, fieldName string) (int, *dst.Field) {
 	for key, field := range fields.List {
+		if len(field.Names) == 0 && embeddedName(field.Type) == fieldName {
+			return key, field
+		}
 		for _, name := range field.Names {
 			if name.Name == fieldName {
 				return key, field
@@ -7,8 +7,10 @@
 package pretty_dst
 
 import (
+	"fmt"
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
 	"github.com/konovenschi/aggretastic-sync/errors"
 	"go/ast"
 	"go/parser"
@@ -18,6 +20,11 @@ import (
 	"regexp"
 )
 
+var (
+	errCantParseSource = fmt.Errorf("Source file can't be parsed: ")
+	errCantRestoreAst  = fmt.Errorf("Ast can't be restored from dst: ")
+)
+
 //Container for Dst and it fileset
 type Source struct {
 	FileSet *token.FileSet
@@ -26,10 +33,15 @@ type Source struct {
 
 //Creates new Source Container
 func NewDst(file io.Reader) (src *Source) {
+	return NewNamedDst(token.NewFileSet(), "", file)
+}
+
+//Creates new Source Container for file parsed into existing fileset under its real name
+func NewNamedDst(fileSet *token.FileSet, filename string, file io.Reader) (src *Source) {
 	var err error
-	src = &Source{FileSet: token.NewFileSet()}
-	src.Dst, err = decorator.ParseFile(src.FileSet, "", file, parser.ParseComments)
-	errors.PanicOnError(err, nil)
+	src = &Source{FileSet: fileSet}
+	src.Dst, err = decorator.ParseFile(src.FileSet, filename, file, parser.ParseComments)
+	errors.PanicOnError(errCantParseSource, err)
 	return src
 }
 
@@ -44,6 +56,14 @@ func (src *Source) FindStructure(pattern string) *StructureDeclaration {
 	return nil
 }
 
+//Find all structures matching name pattern
+func (src *Source) FindStructures(pattern string) []*StructureDeclaration {
+	regex, _ := regexp.Compile(pattern)
+	query := newStructureSearchQuery(regex)
+	dst.Walk(query, src.Dst)
+	return query.structures
+}
+
 //Find function by name pattern
 func (src *Source) FindFunction(pattern string) *Function {
 	regex, _ := regexp.Compile(pattern)
@@ -55,22 +75,35 @@ func (src *Source) FindFunction(pattern string) *Function {
 	return nil
 }
 
+//Find all functions and methods matching name pattern
+func (src *Source) FindFunctions(pattern string) []*Function {
+	regex, _ := regexp.Compile(pattern)
+	query := newFunctionSearchQuery(regex)
+	dst.Walk(query, src.Dst)
+	return query.functions
+}
+
 //renames  package
 func (src *Source) RenamePackage(name string) {
 	src.Dst.Name.Name = name
 }
 
-//add new package import
-func (src *Source) AddImport(name string, path string) {
-	importSpec := NewImportSpec(name, path)
+//renames every identifier with given name in file and returns number of renamed identifiers
+func (src *Source) RenameIdentifiers(from string, to string) int {
+	renamed := 0
+	dst.Inspect(src.Dst, func(node dst.Node) bool {
+		if ident, ok := node.(*dst.Ident); ok && ident.Name == from {
+			ident.Name = to
+			renamed++
+		}
+		return true
+	})
+	return renamed
+}
 
-	//create import declaration if not exists
-	if src.Dst.Imports == nil {
-		dec := NewImportDeclaration(importSpec)
-		src.Dst.Decls = append([]dst.Decl{dec}, src.Dst.Decls...)
-	} else {
-		AppendToImport(src.Dst.Decls[0].(*dst.GenDecl), importSpec)
-	}
+//adds comment line at the top of the file, separated from existing comments (e.g. license) by empty line
+func (src *Source) PrependFileComment(comment string) {
+	src.Dst.Decs.Start.Prepend(comment, "\n")
 }
 
 //save changes on disk
@@ -91,3 +124,76 @@ func (src *Source) Restore() (*token.FileSet, *ast.File) {
 	fs, fl, _ := decorator.RestoreFile(src.Dst)
 	return fs, fl
 }
+
+//restore ast into restorer fileset under given file name. Several sources restored
+//with the same restorer can be type-checked together, restorer maps ast nodes back to dst
+func (src *Source) RestoreWith(restorer *decorator.Restorer, filename string) *ast.File {
+	fileRestorer := restorer.FileRestorer()
+	fileRestorer.Name = filename
+	fl, err := fileRestorer.RestoreFile(src.Dst)
+	errors.PanicOnError(errCantRestoreAst, err)
+	return fl
+}
+
+//replaces identifier with selector of given package, decorations are kept
+func (src *Source) QualifyIdentifier(ident *dst.Ident, packageName string) bool {
+	replaced := false
+	dstutil.Apply(src.Dst, func(cursor *dstutil.Cursor) bool {
+		if cursor.Node() != ident {
+			return !replaced
+		}
+		selector := &dst.SelectorExpr{
+			X:   NewIdent(packageName, nil),
+			Sel: NewIdent(ident.Name, nil),
+		}
+		selector.Decs.NodeDecs = ident.Decs.NodeDecs
+		cursor.Replace(selector)
+		replaced = true
+		return false
+	}, nil)
+	return replaced
+}
+
+//removes top-level declaration or single spec of grouped declaration (including imports).
+//Declaration is removed together with its comments
+func (src *Source) RemoveDeclaration(node dst.Node) bool {
+	removed := false
+	decls := []dst.Decl{}
+	for _, decl := range src.Dst.Decls {
+		if decl == node {
+			removed = true
+			continue
+		}
+		if genDecl, ok := decl.(*dst.GenDecl); ok {
+			specs := []dst.Spec{}
+			for _, spec := range genDecl.Specs {
+				if spec != node {
+					specs = append(specs, spec)
+				}
+			}
+			if len(specs) != len(genDecl.Specs) {
+				removed = true
+				//declaration without specs is removed too
+				if len(specs) == 0 {
+					continue
+				}
+				genDecl.Specs = specs
+			}
+		}
+		decls = append(decls, decl)
+	}
+	src.Dst.Decls = decls
+
+	imports := []*dst.ImportSpec{}
+	for _, imp := range src.Dst.Imports {
+		if imp != node {
+			imports = append(imports, imp)
+		}
+	}
+	//imports are added to new declaration if there are none
+	if len(imports) == 0 {
+		imports = nil
+	}
+	src.Dst.Imports = imports
+	return removed
+}
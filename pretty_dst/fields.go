@@ -0,0 +1,270 @@
+package pretty_dst
+
+import (
+	"fmt"
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+//Structure field decorator. Single field can declare several names (a, b int)
+type Field struct {
+	*dst.Field
+}
+
+//creates field decorator
+func DecorateField(field *dst.Field) *Field {
+	return &Field{
+		field,
+	}
+}
+
+//returns declared names. Embedded field has no names
+func (f *Field) GetNames() []string {
+	names := []string{}
+	for _, name := range f.Names {
+		names = append(names, name.Name)
+	}
+	return names
+}
+
+//returns field name, for embedded field it is type name without pointer and package (*elastic.Script -> Script)
+func (f *Field) GetName() string {
+	if len(f.Names) > 0 {
+		return f.Names[0].Name
+	}
+	return embeddedName(f.Type)
+}
+
+//checks if field is embedded type
+func (f *Field) IsEmbedded() bool {
+	return len(f.Names) == 0
+}
+
+//returns name of named type, pointer to named type or qualified type (pkg.Name),
+//empty string for other types
+func (f *Field) GetTypeName() string {
+	return typeName(f.Type)
+}
+
+//replaces field type
+func (f *Field) SetType(_type dst.Expr) {
+	f.Type = _type
+}
+
+//returns value of tag key (json, yaml) and true if key exists
+func (f *Field) GetTag(key string) (string, bool) {
+	for _, pair := range f.tags() {
+		if pair[0] == key {
+			return pair[1], true
+		}
+	}
+	return "", false
+}
+
+//sets value of tag key. Order of other keys is kept, new key is appended
+func (f *Field) SetTag(key string, value string) {
+	pairs := f.tags()
+	found := false
+	for i := range pairs {
+		if pairs[i][0] == key {
+			pairs[i][1] = value
+			found = true
+		}
+	}
+	if !found {
+		pairs = append(pairs, [2]string{key, value})
+	}
+	f.setTags(pairs)
+}
+
+//removes tag key. Tag is dropped if no keys left
+func (f *Field) RemoveTag(key string) {
+	pairs := [][2]string{}
+	for _, pair := range f.tags() {
+		if pair[0] != key {
+			pairs = append(pairs, pair)
+		}
+	}
+	f.setTags(pairs)
+}
+
+//replaces comments above field
+func (f *Field) SetDoc(lines ...string) {
+	f.Decs.Start.Clear()
+	for _, line := range lines {
+		f.Decs.Start.Append(commentText(line))
+	}
+	if len(lines) > 0 {
+		f.Decs.Before = dst.NewLine
+	}
+}
+
+//replaces comment at the end of field line
+func (f *Field) SetComment(comment string) {
+	f.Decs.End.Clear()
+	if comment != "" {
+		f.Decs.End.Append(commentText(comment))
+	}
+}
+
+//returns key-value pairs of struct tag in declaration order
+func (f *Field) tags() [][2]string {
+	if f.Tag == nil {
+		return [][2]string{}
+	}
+	tag, err := strconv.Unquote(f.Tag.Value)
+	if err != nil {
+		return [][2]string{}
+	}
+	return parseTag(tag)
+}
+
+func (f *Field) setTags(pairs [][2]string) {
+	if len(pairs) == 0 {
+		f.Tag = nil
+		return
+	}
+	parts := []string{}
+	for _, pair := range pairs {
+		parts = append(parts, pair[0]+":"+strconv.Quote(pair[1]))
+	}
+	f.Tag = &dst.BasicLit{
+		Kind:  token.STRING,
+		Value: "`" + strings.Join(parts, " ") + "`",
+	}
+}
+
+//parses conventional struct tag (key:"value" key2:"value2"), same rules as reflect.StructTag
+func parseTag(tag string) [][2]string {
+	pairs := [][2]string{}
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		value, err := strconv.Unquote(tag[:i+1])
+		if err != nil {
+			break
+		}
+		pairs = append(pairs, [2]string{key, value})
+		tag = tag[i+1:]
+	}
+	return pairs
+}
+
+//returns name of embedded field: type name without pointer and package
+func embeddedName(expr dst.Expr) string {
+	name := typeName(expr)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+//prefixes comment text with slashes if missing
+func commentText(text string) string {
+	if strings.HasPrefix(text, "//") || strings.HasPrefix(text, "/*") {
+		return text
+	}
+	return "// " + text
+}
+
+//creates type expression from its source (*Injectable, map[string]interface{}, func(int) error, []*elastic.Script)
+func NewTypeExpression(_type string) (dst.Expr, error) {
+	expr, err := parser.ParseExpr(_type)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type %q: %s", _type, err)
+	}
+	node, err := decorator.NewDecorator(token.NewFileSet()).DecorateNode(expr)
+	if err != nil {
+		return nil, err
+	}
+	return node.(dst.Expr), nil
+}
+
+//creates pointer type (*X)
+func NewPointerType(_type dst.Expr) *dst.StarExpr {
+	return &dst.StarExpr{
+		X: _type,
+	}
+}
+
+//creates qualified type (pkg.Name)
+func NewSelectorType(packageName string, name string) *dst.SelectorExpr {
+	return &dst.SelectorExpr{
+		X:   NewIdent(packageName, nil),
+		Sel: NewIdent(name, nil),
+	}
+}
+
+//creates map type
+func NewMapType(key dst.Expr, value dst.Expr) *dst.MapType {
+	return &dst.MapType{
+		Key:   key,
+		Value: value,
+	}
+}
+
+//creates slice type
+func NewSliceType(element dst.Expr) *dst.ArrayType {
+	return &dst.ArrayType{
+		Elt: element,
+	}
+}
+
+//creates function type with unnamed parameters and results
+func NewFuncType(params []dst.Expr, results []dst.Expr) *dst.FuncType {
+	funcType := &dst.FuncType{
+		Func:   true,
+		Params: &dst.FieldList{List: []*dst.Field{}},
+	}
+	for _, param := range params {
+		funcType.Params.List = append(funcType.Params.List, &dst.Field{Type: param})
+	}
+	if len(results) > 0 {
+		funcType.Results = &dst.FieldList{List: []*dst.Field{}, Opening: len(results) > 1, Closing: len(results) > 1}
+		for _, result := range results {
+			funcType.Results.List = append(funcType.Results.List, &dst.Field{Type: result})
+		}
+	}
+	return funcType
+}
+
+//creates embedded field
+func NewEmbeddedField(_type dst.Expr) *dst.Field {
+	return &dst.Field{
+		Type: _type,
+	}
+}
+
+//creates named field of any type
+func NewTypedField(name string, _type dst.Expr) *dst.Field {
+	variable := NewVariableObject(name)
+	field := &dst.Field{
+		Names: []*dst.Ident{{
+			Name: name,
+			Obj:  variable,
+		}},
+		Type: _type,
+	}
+	variable.Decl = field
+	return field
+}
@@ -0,0 +1,191 @@
+package pretty_dst
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		expected [][2]string
+	}{
+		{"empty tag", "", [][2]string{}},
+		{"single key", `json:"name"`, [][2]string{{"json", "name"}}},
+		{"several keys", `json:"name,omitempty"  yaml:"name"`, [][2]string{{"json", "name,omitempty"}, {"yaml", "name"}}},
+		{"escaped quotes", `json:"a\"b" yaml:"c"`, [][2]string{{"json", `a"b`}, {"yaml", "c"}}},
+		{"parsing stops at broken pair", `json:"name" yaml name`, [][2]string{{"json", "name"}}},
+		{"unterminated value", `json:"name`, [][2]string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := parseTag(test.tag); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFieldTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		modify   func(field *Field)
+		expected string
+	}{
+		{
+			name:  "key is added to field without tag",
+			field: "a int",
+			modify: func(field *Field) {
+				field.SetTag("json", "a")
+			},
+			expected: "a int `json:\"a\"`",
+		},
+		{
+			name:  "key is appended",
+			field: "a int `json:\"a\"`",
+			modify: func(field *Field) {
+				field.SetTag("yaml", "a")
+			},
+			expected: "a int `json:\"a\" yaml:\"a\"`",
+		},
+		{
+			name:  "key is replaced in place",
+			field: "a int `json:\"a\" yaml:\"a\"`",
+			modify: func(field *Field) {
+				field.SetTag("json", "b,omitempty")
+			},
+			expected: "a int `json:\"b,omitempty\" yaml:\"a\"`",
+		},
+		{
+			name:  "escaped quotes are kept",
+			field: "a int `json:\"a\\\"b\"`",
+			modify: func(field *Field) {
+				field.SetTag("yaml", "c\"d")
+			},
+			expected: "a int `json:\"a\\\"b\" yaml:\"c\\\"d\"`",
+		},
+		{
+			name:  "interpreted string tag",
+			field: "a int \"json:\\\"a\\\"\"",
+			modify: func(field *Field) {
+				field.SetTag("yaml", "a")
+			},
+			expected: "a int `json:\"a\" yaml:\"a\"`",
+		},
+		{
+			name:  "key is removed",
+			field: "a int `json:\"a\" yaml:\"a\"`",
+			modify: func(field *Field) {
+				field.RemoveTag("json")
+			},
+			expected: "a int `yaml:\"a\"`",
+		},
+		{
+			name:  "tag is dropped with last key",
+			field: "a int `json:\"a\"`",
+			modify: func(field *Field) {
+				field.RemoveTag("json")
+			},
+			expected: "a int",
+		},
+		{
+			name:  "missing key is not removed from field without tag",
+			field: "a int",
+			modify: func(field *Field) {
+				field.RemoveTag("json")
+			},
+			expected: "a int",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader("package a\n\ntype X struct {\n\t" + test.field + "\n}\n"))
+			field := src.FindStructure("^X$").GetField("a")
+			test.modify(field)
+			expected := formatCode(t, "package a\n\ntype X struct {\n\t"+test.expected+"\n}\n")
+			if actual := formatSource(t, src); actual != expected {
+				t.Errorf("unexpected output:\n%s", actual)
+			}
+		})
+	}
+}
+
+func TestFieldGetTag(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		key   string
+		value string
+		found bool
+	}{
+		{"existing key", "a int `json:\"a,omitempty\" yaml:\"b\"`", "yaml", "b", true},
+		{"escaped quotes", "a int `json:\"a\\\"b\"`", "json", `a"b`, true},
+		{"empty value", "a int `json:\"\"`", "json", "", true},
+		{"missing key", "a int `json:\"a\"`", "yaml", "", false},
+		{"field without tag", "a int", "json", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader("package a\n\ntype X struct {\n\t" + test.field + "\n}\n"))
+			value, found := src.FindStructure("^X$").GetField("a").GetTag(test.key)
+			if value != test.value || found != test.found {
+				t.Errorf("expected %q, %v, got %q, %v", test.value, test.found, value, found)
+			}
+		})
+	}
+}
+
+func TestStructureAddField(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(structure *StructureDeclaration) *Field
+		field    string
+		expected string
+	}{
+		{
+			name: "named field",
+			modify: func(structure *StructureDeclaration) *Field {
+				return structure.AddField("meta", "map[string]interface{}")
+			},
+			field:    "meta",
+			expected: "meta map[string]interface{}",
+		},
+		{
+			name: "embedded pointer",
+			modify: func(structure *StructureDeclaration) *Field {
+				return structure.AddEmbeddedField("*Injectable")
+			},
+			field:    "Injectable",
+			expected: "*Injectable",
+		},
+		{
+			name: "embedded qualified type",
+			modify: func(structure *StructureDeclaration) *Field {
+				return structure.AddEmbeddedField("elastic.Script")
+			},
+			field:    "Script",
+			expected: "elastic.Script",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader("package a\n\ntype X struct {\n\ta int\n}\n"))
+			structure := src.FindStructure("^X$")
+			field := test.modify(structure)
+			if field.GetName() != test.field || structure.GetField(test.field) == nil {
+				t.Errorf("expected field %q, got %q", test.field, field.GetName())
+			}
+			expected := formatCode(t, "package a\n\ntype X struct {\n\ta int\n\t"+test.expected+"\n}\n")
+			if actual := formatSource(t, src); actual != expected {
+				t.Errorf("unexpected output:\n%s", actual)
+			}
+		})
+	}
+}
@@ -95,24 +95,27 @@ func NewCallExpression(name string, arguments ...string) *dst.CallExpr {
 	}
 }
 
-//creates new filled Data Field (for structures and interfaces)
+//creates new filled Data Field (for structures and interfaces). Type is Go source of type expression,
+//it is used as identifier if can't be parsed
 func NewDataField(name string, _type string) *dst.Field {
-	variable := NewVariableObject(name)
-
-	field := &dst.Field{
-		Names: []*dst.Ident{{
-			Name: name,
-			Obj:  variable,
-		}},
-	}
-
-	variable.Decl = field
-
-	field.Type = &dst.Ident{
-		Name: _type,
+	expr, err := NewTypeExpression(_type)
+	if err != nil {
+		expr = NewIdent(_type, nil)
 	}
+	return NewTypedField(name, expr)
+}
 
-	return field
+//returns name of named type, pointer to named type or qualified type (pkg.Name)
+func typeName(expr dst.Expr) string {
+	switch t := expr.(type) {
+	case *dst.Ident:
+		return t.Name
+	case *dst.StarExpr:
+		return typeName(t.X)
+	case *dst.SelectorExpr:
+		return typeName(t.X) + "." + t.Sel.Name
+	}
+	return ""
 }
 
 //Checks if TypeSpec contain Structure object
@@ -0,0 +1,176 @@
+package pretty_dst
+
+import (
+	"github.com/dave/dst"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//returns import declarations of file
+func (src *Source) importDeclarations() []*dst.GenDecl {
+	declarations := []*dst.GenDecl{}
+	for _, decl := range src.Dst.Decls {
+		if genDecl, ok := decl.(*dst.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			declarations = append(declarations, genDecl)
+		}
+	}
+	return declarations
+}
+
+//returns all import specs of file
+func (src *Source) GetImports() []*dst.ImportSpec {
+	imports := []*dst.ImportSpec{}
+	for _, declaration := range src.importDeclarations() {
+		for _, spec := range declaration.Specs {
+			imports = append(imports, spec.(*dst.ImportSpec))
+		}
+	}
+	return imports
+}
+
+//find import by package path
+func (src *Source) FindImport(path string) *dst.ImportSpec {
+	for _, imp := range src.GetImports() {
+		if ImportPath(imp) == path {
+			return imp
+		}
+	}
+	return nil
+}
+
+//checks if package with given path is imported
+func (src *Source) HasImport(path string) bool {
+	return src.FindImport(path) != nil
+}
+
+//add new package import with optional alias. Import of the same package under the same name is not duplicated
+func (src *Source) AddImport(name string, path string) *dst.ImportSpec {
+	for _, imp := range src.GetImports() {
+		if ImportPath(imp) == path && importAlias(imp) == name {
+			return imp
+		}
+	}
+	importSpec := NewImportSpec(name, path)
+
+	//create import declaration if not exists. Imports should precede other declarations
+	declarations := src.importDeclarations()
+	if len(declarations) == 0 {
+		dec := NewImportDeclaration(importSpec)
+		dec.Decs.After = dst.EmptyLine
+		src.Dst.Decls = append([]dst.Decl{dec}, src.Dst.Decls...)
+	} else {
+		AppendToImport(declarations[0], importSpec)
+	}
+	src.Dst.Imports = append(src.Dst.Imports, importSpec)
+	return importSpec
+}
+
+//remove every import of package. Returns false if package is not imported
+func (src *Source) RemoveImport(path string) bool {
+	removed := false
+	for _, imp := range src.GetImports() {
+		if ImportPath(imp) == path {
+			removed = src.RemoveDeclaration(imp) || removed
+		}
+	}
+	return removed
+}
+
+//returns imports with explicit name which are not referenced in file. Blank and dot imports are skipped
+func (src *Source) UnusedAliasedImports() []*dst.ImportSpec {
+	used := map[string]bool{}
+	dst.Inspect(src.Dst, func(node dst.Node) bool {
+		if selector, ok := node.(*dst.SelectorExpr); ok {
+			if ident, ok := selector.X.(*dst.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	unused := []*dst.ImportSpec{}
+	for _, imp := range src.GetImports() {
+		name := importAlias(imp)
+		if name == "" || name == "_" || name == "." || used[name] {
+			continue
+		}
+		unused = append(unused, imp)
+	}
+	return unused
+}
+
+//merge import declarations into single one, remove duplicates and sort imports:
+//standard library first, then third-party packages separated by empty line
+func (src *Source) SortImports() {
+	declarations := src.importDeclarations()
+	if len(declarations) == 0 {
+		return
+	}
+
+	imports := []*dst.ImportSpec{}
+	seen := map[string]bool{}
+	for _, imp := range src.GetImports() {
+		key := importAlias(imp) + " " + ImportPath(imp)
+		if !seen[key] {
+			seen[key] = true
+			imports = append(imports, imp)
+		}
+	}
+	sort.SliceStable(imports, func(i, j int) bool {
+		if IsStandardImport(ImportPath(imports[i])) != IsStandardImport(ImportPath(imports[j])) {
+			return IsStandardImport(ImportPath(imports[i]))
+		}
+		return ImportPath(imports[i]) < ImportPath(imports[j])
+	})
+
+	specs := []dst.Spec{}
+	for i, imp := range imports {
+		imp.Decs.Before = dst.NewLine
+		imp.Decs.After = dst.NewLine
+		if i > 0 && IsStandardImport(ImportPath(imports[i-1])) && !IsStandardImport(ImportPath(imp)) {
+			imp.Decs.Before = dst.EmptyLine
+		}
+		specs = append(specs, imp)
+	}
+
+	first := declarations[0]
+	first.Specs = specs
+	first.Lparen = len(specs) > 1 || first.Lparen
+	first.Rparen = first.Lparen
+
+	decls := []dst.Decl{}
+	for _, decl := range src.Dst.Decls {
+		if genDecl, ok := decl.(*dst.GenDecl); ok && genDecl.Tok == token.IMPORT && genDecl != first {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	src.Dst.Decls = decls
+	src.Dst.Imports = imports
+}
+
+//returns unquoted import path
+func ImportPath(imp *dst.ImportSpec) string {
+	value, err := strconv.Unquote(imp.Path.Value)
+	if err != nil {
+		return imp.Path.Value
+	}
+	return value
+}
+
+//checks if package belongs to standard library (first path element has no dot)
+func IsStandardImport(importPath string) bool {
+	return !strings.Contains(strings.Split(importPath, "/")[0], ".")
+}
+
+func importAlias(imp *dst.ImportSpec) string {
+	if imp.Name == nil {
+		return ""
+	}
+	return imp.Name.Name
+}
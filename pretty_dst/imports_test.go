@@ -0,0 +1,121 @@
+package pretty_dst
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImports(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		modify   func(src *Source)
+		expected string
+	}{
+		{
+			name:   "import declaration is created",
+			source: "package a\n\nvar x = 1\n",
+			modify: func(src *Source) {
+				src.AddImport("", "fmt")
+			},
+			expected: "package a\n\nimport (\n\t\"fmt\"\n)\n\nvar x = 1\n",
+		},
+		{
+			name:   "same import is not duplicated",
+			source: "package a\n\nimport \"fmt\"\n",
+			modify: func(src *Source) {
+				src.AddImport("", "fmt")
+				src.AddImport("", "fmt")
+			},
+			expected: "package a\n\nimport \"fmt\"\n",
+		},
+		{
+			name:   "import under other name is added",
+			source: "package a\n\nimport \"fmt\"\n",
+			modify: func(src *Source) {
+				src.AddImport("f", "fmt")
+				src.AddImport("f", "fmt")
+			},
+			expected: "package a\n\nimport (\n\t\"fmt\"\n\tf \"fmt\"\n)\n",
+		},
+		{
+			name:   "every import of package is removed",
+			source: "package a\n\nimport (\n\t\"fmt\"\n\tf \"fmt\"\n\t\"strings\"\n)\n",
+			modify: func(src *Source) {
+				src.RemoveImport("fmt")
+			},
+			expected: "package a\n\nimport (\n\t\"strings\"\n)\n",
+		},
+		{
+			name:   "missing import is not removed",
+			source: "package a\n\nimport \"fmt\"\n",
+			modify: func(src *Source) {
+				src.RemoveImport("strings")
+			},
+			expected: "package a\n\nimport \"fmt\"\n",
+		},
+		{
+			name:   "standard imports are grouped before third-party ones",
+			source: "package a\n\nimport (\n\t\"github.com/b/c\"\n\t\"strings\"\n)\n\nimport \"fmt\"\n\nimport (\n\t\"github.com/a/b\"\n\t\"fmt\"\n)\n",
+			modify: func(src *Source) {
+				src.SortImports()
+			},
+			expected: "package a\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n\n\t\"github.com/a/b\"\n\t\"github.com/b/c\"\n)\n",
+		},
+		{
+			name:   "aliases are kept while sorting",
+			source: "package a\n\nimport (\n\tz \"strings\"\n\telastic \"gopkg.in/olivere/elastic.v6\"\n\t\"fmt\"\n)\n",
+			modify: func(src *Source) {
+				src.SortImports()
+			},
+			expected: "package a\n\nimport (\n\t\"fmt\"\n\tz \"strings\"\n\n\telastic \"gopkg.in/olivere/elastic.v6\"\n)\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader(test.source))
+			test.modify(src)
+			if actual := formatSource(t, src); actual != formatCode(t, test.expected) {
+				t.Errorf("unexpected output:\n%s", actual)
+			}
+		})
+	}
+}
+
+func TestUnusedAliasedImports(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected []string
+	}{
+		{
+			name:     "used alias",
+			source:   "package a\n\nimport e \"gopkg.in/olivere/elastic.v6\"\n\nvar x e.Query\n",
+			expected: []string{},
+		},
+		{
+			name:     "unused alias",
+			source:   "package a\n\nimport e \"gopkg.in/olivere/elastic.v6\"\n\nvar x int\n",
+			expected: []string{"gopkg.in/olivere/elastic.v6"},
+		},
+		{
+			name:     "imports without alias, blank and dot imports are skipped",
+			source:   "package a\n\nimport (\n\t\"fmt\"\n\t_ \"net/http/pprof\"\n\t. \"strings\"\n)\n",
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader(test.source))
+			actual := []string{}
+			for _, imp := range src.UnusedAliasedImports() {
+				actual = append(actual, ImportPath(imp))
+			}
+			if strings.Join(actual, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package pretty_dst
+
+import (
+	"fmt"
+	"github.com/dave/dst"
+	"go/token"
+	"regexp"
+)
+
+//Interface declaration decorator
+type InterfaceDeclaration struct {
+	name    *dst.Ident
+	methods *dst.FieldList
+	origin  *dst.TypeSpec
+}
+
+//creates interface decorator
+func DecorateInterface(dstType *dst.TypeSpec) (*InterfaceDeclaration, error) {
+	if !IsInterface(dstType) {
+		return nil, fmt.Errorf("not interface ")
+	}
+
+	return &InterfaceDeclaration{
+		name:    dstType.Name,
+		methods: dstType.Type.(*dst.InterfaceType).Methods,
+		origin:  dstType,
+	}, nil
+}
+
+//Checks if TypeSpec contain Interface object
+func IsInterface(dstType *dst.TypeSpec) bool {
+	_, ok := dstType.Type.(*dst.InterfaceType)
+	return ok
+}
+
+//Find interface by name pattern
+func (src *Source) FindInterface(pattern string) *InterfaceDeclaration {
+	regex, _ := regexp.Compile(pattern)
+	for _, decl := range src.Dst.Decls {
+		genDecl, ok := decl.(*dst.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			iface, err := DecorateInterface(spec.(*dst.TypeSpec))
+			if err == nil && regex.MatchString(iface.GetName()) {
+				return iface
+			}
+		}
+	}
+	return nil
+}
+
+//Find interface by name pattern in any file
+func (pkg *Package) FindInterface(pattern string) *InterfaceDeclaration {
+	for _, filename := range pkg.Filenames() {
+		if iface := pkg.files[filename].FindInterface(pattern); iface != nil {
+			return iface
+		}
+	}
+	return nil
+}
+
+//returns original dst interface declaration
+func (i *InterfaceDeclaration) Extract() *dst.TypeSpec {
+	return i.origin
+}
+
+//returns interface name
+func (i *InterfaceDeclaration) GetName() string {
+	return i.name.Name
+}
+
+//returns declared methods in declaration order, embedded interfaces are not included
+func (i *InterfaceDeclaration) GetMethods() []*Field {
+	methods := []*Field{}
+	for _, field := range i.methods.List {
+		if len(field.Names) > 0 {
+			methods = append(methods, DecorateField(field))
+		}
+	}
+	return methods
+}
+
+//returns method by name or nil
+func (i *InterfaceDeclaration) GetMethod(name string) *Field {
+	for _, method := range i.GetMethods() {
+		if method.GetName() == name {
+			return method
+		}
+	}
+	return nil
+}
+
+//checks if interface declares method (embedded interfaces are not checked)
+func (i *InterfaceDeclaration) HasMethod(name string) bool {
+	return i.GetMethod(name) != nil
+}
+
+//declares new method. Signature is Go source of parameters and results: "(name string) (interface{}, error)"
+func (i *InterfaceDeclaration) AddMethod(name string, signature string) (*Field, error) {
+	funcType, err := newMethodSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	field := NewTypedField(name, funcType)
+	i.methods.List = append(i.methods.List, field)
+	return DecorateField(field), nil
+}
+
+//declares method or replaces its signature if differs. Comments of existing method are kept.
+//Returns true if interface was changed
+func (i *InterfaceDeclaration) SetMethod(name string, signature string) (bool, error) {
+	method := i.GetMethod(name)
+	if method == nil {
+		_, err := i.AddMethod(name, signature)
+		return err == nil, err
+	}
+	funcType, err := newMethodSignature(signature)
+	if err != nil {
+		return false, err
+	}
+	if matchNode(funcType, method.Type, Placeholders{}) {
+		return false, nil
+	}
+	method.SetType(funcType)
+	return true, nil
+}
+
+//removes method by name. Returns false if method is not declared
+func (i *InterfaceDeclaration) RemoveMethod(name string) bool {
+	for index, field := range i.methods.List {
+		if len(field.Names) > 0 && field.Names[0].Name == name {
+			removeField(i.methods, index)
+			return true
+		}
+	}
+	return false
+}
+
+//returns type names of embedded interfaces (Injectable, elastic.Aggregation)
+func (i *InterfaceDeclaration) GetEmbedded() []string {
+	embedded := []string{}
+	for _, field := range i.methods.List {
+		if len(field.Names) == 0 {
+			embedded = append(embedded, typeName(field.Type))
+		}
+	}
+	return embedded
+}
+
+//Checks if interface embeds type with such name
+func (i *InterfaceDeclaration) IsEmbedded(name string) bool {
+	for _, embedded := range i.GetEmbedded() {
+		if embedded == name {
+			return true
+		}
+	}
+	return false
+}
+
+//embeds interface, embedded interfaces are placed before methods
+func (i *InterfaceDeclaration) AddEmbedded(_type string) (*Field, error) {
+	expr, err := NewTypeExpression(_type)
+	if err != nil {
+		return nil, err
+	}
+	field := NewEmbeddedField(expr)
+	position := 0
+	for position < len(i.methods.List) && len(i.methods.List[position].Names) == 0 {
+		position++
+	}
+	list := append([]*dst.Field{}, i.methods.List[:position]...)
+	list = append(list, field)
+	i.methods.List = append(list, i.methods.List[position:]...)
+	return DecorateField(field), nil
+}
+
+//removes embedded interface by type name. Returns false if type is not embedded
+func (i *InterfaceDeclaration) RemoveEmbedded(name string) bool {
+	for index, field := range i.methods.List {
+		if len(field.Names) == 0 && typeName(field.Type) == name {
+			removeField(i.methods, index)
+			return true
+		}
+	}
+	return false
+}
+
+//parses method signature into function type without func keyword
+func newMethodSignature(signature string) (*dst.FuncType, error) {
+	expr, err := NewTypeExpression("func" + signature)
+	if err != nil {
+		return nil, err
+	}
+	funcType, ok := expr.(*dst.FuncType)
+	if !ok {
+		return nil, fmt.Errorf("invalid method signature %q", signature)
+	}
+	funcType.Func = false
+	return funcType, nil
+}
@@ -0,0 +1,148 @@
+package pretty_dst
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const interfacesSource = `package a
+
+type X struct{}
+
+type Aggregation interface {
+	Injectable
+	//source of aggregation
+	Source() (interface{}, error)
+	Name() string
+}
+`
+
+func TestSourceFindInterface(t *testing.T) {
+	src := NewDst(strings.NewReader(interfacesSource))
+	if src.FindInterface("^X$") != nil {
+		t.Error("structure is found as interface")
+	}
+	iface := src.FindInterface("^Agg")
+	if iface == nil {
+		t.Fatal("interface is not found")
+	}
+
+	methods := []string{}
+	for _, method := range iface.GetMethods() {
+		methods = append(methods, method.GetName())
+	}
+	if expected := []string{"Source", "Name"}; !reflect.DeepEqual(methods, expected) {
+		t.Errorf("expected methods %v, got %v", expected, methods)
+	}
+	if expected := []string{"Injectable"}; !reflect.DeepEqual(iface.GetEmbedded(), expected) {
+		t.Errorf("expected embedded %v, got %v", expected, iface.GetEmbedded())
+	}
+	if !iface.IsEmbedded("Injectable") || iface.IsEmbedded("Source") {
+		t.Error("embedded interfaces are not distinguished from methods")
+	}
+	if !iface.HasMethod("Name") || iface.HasMethod("Injectable") {
+		t.Error("methods are not distinguished from embedded interfaces")
+	}
+}
+
+func TestInterfaceEditing(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(iface *InterfaceDeclaration) (bool, error)
+		changed bool
+		old     string
+		new     string
+	}{
+		{
+			name: "method is added",
+			modify: func(iface *InterfaceDeclaration) (bool, error) {
+				return iface.SetMethod("Meta", "(key string) (interface{}, error)")
+			},
+			changed: true,
+			old:     "\tName() string\n",
+			new:     "\tName() string\n\tMeta(key string) (interface{}, error)\n",
+		},
+		{
+			name: "signature is replaced and comment is kept",
+			modify: func(iface *InterfaceDeclaration) (bool, error) {
+				return iface.SetMethod("Source", "() (map[string]interface{}, error)")
+			},
+			changed: true,
+			old:     "\tSource() (interface{}, error)\n",
+			new:     "\tSource() (map[string]interface{}, error)\n",
+		},
+		{
+			name: "same signature is not changed",
+			modify: func(iface *InterfaceDeclaration) (bool, error) {
+				return iface.SetMethod("Name", "()string")
+			},
+			changed: false,
+		},
+		{
+			name: "method is removed",
+			modify: func(iface *InterfaceDeclaration) (bool, error) {
+				return iface.RemoveMethod("Name"), nil
+			},
+			changed: true,
+			old:     "\tName() string\n",
+			new:     "",
+		},
+		{
+			name: "embedded interface is not removed as method",
+			modify: func(iface *InterfaceDeclaration) (bool, error) {
+				return iface.RemoveMethod("Injectable"), nil
+			},
+			changed: false,
+		},
+		{
+			name: "embedded interface is placed before methods",
+			modify: func(iface *InterfaceDeclaration) (bool, error) {
+				_, err := iface.AddEmbedded("elastic.Aggregation")
+				return err == nil, err
+			},
+			changed: true,
+			old:     "\tInjectable\n",
+			new:     "\tInjectable\n\telastic.Aggregation\n",
+		},
+		{
+			name: "embedded interface is removed",
+			modify: func(iface *InterfaceDeclaration) (bool, error) {
+				return iface.RemoveEmbedded("Injectable"), nil
+			},
+			changed: true,
+			old:     "\tInjectable\n",
+			new:     "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader(interfacesSource))
+			changed, err := test.modify(src.FindInterface("^Aggregation$"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != test.changed {
+				t.Errorf("expected changed %v, got %v", test.changed, changed)
+			}
+			expected := formatCode(t, strings.Replace(interfacesSource, test.old, test.new, 1))
+			if actual := formatSource(t, src); actual != expected {
+				t.Errorf("unexpected output:\n%s", actual)
+			}
+		})
+	}
+}
+
+func TestInterfaceInvalidSignature(t *testing.T) {
+	src := NewDst(strings.NewReader(interfacesSource))
+	iface := src.FindInterface("^Aggregation$")
+	for _, signature := range []string{"(", "() (", "int"} {
+		if _, err := iface.SetMethod("Meta", signature); err == nil {
+			t.Errorf("expected error for signature %q", signature)
+		}
+	}
+	if iface.HasMethod("Meta") {
+		t.Error("method with invalid signature is added")
+	}
+}
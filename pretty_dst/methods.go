@@ -0,0 +1,179 @@
+package pretty_dst
+
+import (
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+)
+
+//Find all methods of receiver type (value and pointer receivers)
+func (src *Source) FindMethods(receiverType string) []*Function {
+	methods := []*Function{}
+	for _, decl := range src.Dst.Decls {
+		if function, ok := decl.(*dst.FuncDecl); ok {
+			if method := DecorateFunction(function); method.IsMethod() && method.GetReceiverType() == receiverType {
+				methods = append(methods, method)
+			}
+		}
+	}
+	return methods
+}
+
+//Find method of receiver type by name
+func (src *Source) FindMethod(receiverType string, name string) *Function {
+	for _, method := range src.FindMethods(receiverType) {
+		if method.GetName() == name {
+			return method
+		}
+	}
+	return nil
+}
+
+//Find constructor of type: first function (not method) with body which returns only pointer to type
+func (src *Source) FindConstructor(name string) *Function {
+	for _, decl := range src.Dst.Decls {
+		function, ok := decl.(*dst.FuncDecl)
+		if !ok || function.Body == nil {
+			continue
+		}
+		constructor := DecorateFunction(function)
+		results := constructor.GetResults()
+		if constructor.IsMethod() || len(results) != 1 {
+			continue
+		}
+		if pointer, ok := results[0].(*dst.StarExpr); ok && typeName(pointer.X) == name {
+			return constructor
+		}
+	}
+	return nil
+}
+
+//adds method declaration after the last method of its receiver type or to the end of file
+func (src *Source) AddMethod(method *dst.FuncDecl) *Function {
+	decorated := DecorateFunction(method)
+	position := len(src.Dst.Decls)
+	for i, decl := range src.Dst.Decls {
+		if function, ok := decl.(*dst.FuncDecl); ok && DecorateFunction(function).GetReceiverType() == decorated.GetReceiverType() {
+			position = i + 1
+		}
+	}
+	method.Decs.Before = dst.EmptyLine
+	decls := append([]dst.Decl{}, src.Dst.Decls[:position]...)
+	decls = append(decls, method)
+	src.Dst.Decls = append(decls, src.Dst.Decls[position:]...)
+	return decorated
+}
+
+//removes method of receiver type. Returns false if method is not declared in file
+func (src *Source) RemoveMethod(receiverType string, name string) bool {
+	method := src.FindMethod(receiverType, name)
+	if method == nil {
+		return false
+	}
+	return src.RemoveDeclaration(method.Extract())
+}
+
+//copies method (from any file) to other receiver type, comments are copied too
+func (src *Source) CopyMethod(method *Function, receiverType string) *Function {
+	clone := dst.Clone(method.Extract()).(*dst.FuncDecl)
+	DecorateFunction(clone).SetReceiverType(receiverType)
+	return src.AddMethod(clone)
+}
+
+//Find all methods of receiver type in all files
+func (pkg *Package) FindMethods(receiverType string) []*Function {
+	methods := []*Function{}
+	for _, filename := range pkg.Filenames() {
+		methods = append(methods, pkg.files[filename].FindMethods(receiverType)...)
+	}
+	return methods
+}
+
+//checks if function is method
+func (f *Function) IsMethod() bool {
+	return f.origin.Recv != nil && len(f.origin.Recv.List) > 0
+}
+
+//checks if method has pointer receiver
+func (f *Function) IsPointerReceiver() bool {
+	if !f.IsMethod() {
+		return false
+	}
+	_, ok := f.origin.Recv.List[0].Type.(*dst.StarExpr)
+	return ok
+}
+
+//returns receiver variable name or empty string if receiver is unnamed
+func (f *Function) GetReceiverName() string {
+	if !f.IsMethod() || len(f.origin.Recv.List[0].Names) == 0 {
+		return ""
+	}
+	return f.origin.Recv.List[0].Names[0].Name
+}
+
+//changes receiver type, pointer receiver stays pointer
+func (f *Function) SetReceiverType(name string) {
+	if !f.IsMethod() {
+		return
+	}
+	receiver := f.origin.Recv.List[0]
+	var _type dst.Expr = NewIdent(name, nil)
+	if f.IsPointerReceiver() {
+		_type = NewPointerType(_type)
+	}
+	receiver.Type = _type
+}
+
+//returns result types, results declared together (a, b int) are returned separately
+func (f *Function) GetResults() []dst.Expr {
+	results := []dst.Expr{}
+	if f.origin.Type.Results == nil {
+		return results
+	}
+	for _, field := range f.origin.Type.Results.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			results = append(results, field.Type)
+		}
+	}
+	return results
+}
+
+//replaces named type in results (*elastic.X -> *X, []elastic.X -> []X). Pointers, slices and maps are kept.
+//Returns number of replaced types
+func (f *Function) ReplaceResultType(from string, to string) int {
+	if f.origin.Type.Results == nil {
+		return 0
+	}
+	return replaceType(f.origin.Type.Results, from, to)
+}
+
+//replaces named type in parameters and results. Returns number of replaced types
+func (f *Function) ReplaceSignatureType(from string, to string) int {
+	return replaceType(f.origin.Type, from, to)
+}
+
+//replaces named type (X or pkg.X) in node with other type
+func replaceType(node dst.Node, from string, to string) int {
+	replaced := 0
+	dstutil.Apply(node, func(cursor *dstutil.Cursor) bool {
+		switch n := cursor.Node().(type) {
+		case *dst.Ident, *dst.SelectorExpr:
+			//field names and selector parts are not types
+			if cursor.Name() == "Names" || cursor.Name() == "Sel" || typeName(n.(dst.Expr)) != from {
+				return true
+			}
+			_type, err := NewTypeExpression(to)
+			if err != nil {
+				return false
+			}
+			cursor.Replace(_type)
+			replaced++
+			return false
+		}
+		return true
+	}, nil)
+	return replaced
+}
@@ -0,0 +1,165 @@
+package pretty_dst
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const methodsSource = `package a
+
+type X struct{}
+
+type Y struct{}
+
+func NewX() *X {
+	return &X{}
+}
+
+func (x *X) A() *elastic.X {
+	return nil
+}
+
+func (x X) B(v elastic.X, m map[string]*elastic.X) ([]elastic.X, error) {
+	return nil, nil
+}
+
+func (y *Y) C() {}
+`
+
+func TestSourceFindMethods(t *testing.T) {
+	tests := []struct {
+		name     string
+		receiver string
+		expected []string
+	}{
+		{"pointer and value receivers", "X", []string{"A", "B"}},
+		{"single method", "Y", []string{"C"}},
+		{"type without methods", "Z", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader(methodsSource))
+			if actual := functionNames(src.FindMethods(test.receiver)); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSourceFindConstructor(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"pointer result", methodsSource, "NewX"},
+		{"value result", "package a\n\ntype X struct{}\n\nfunc NewX() X {\n\treturn X{}\n}\n", ""},
+		{"several results", "package a\n\ntype X struct{}\n\nfunc NewX() (*X, error) {\n\treturn nil, nil\n}\n", ""},
+		{"method returning pointer", "package a\n\ntype X struct{}\n\nfunc (x *X) Clone() *X {\n\treturn x\n}\n", ""},
+		{"function of other type", "package a\n\ntype X struct{}\n\nfunc NewY() *Y {\n\treturn nil\n}\n\nfunc NewX() *X {\n\treturn nil\n}\n", "NewX"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader(test.source))
+			actual := ""
+			if constructor := src.FindConstructor("X"); constructor != nil {
+				actual = constructor.GetName()
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSourceMethodEditing(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(src *Source)
+		old    string
+		new    string
+	}{
+		{
+			name: "method is copied after methods of other receiver type",
+			modify: func(src *Source) {
+				src.CopyMethod(src.FindMethod("X", "A"), "Y")
+			},
+			old: "func (y *Y) C() {}\n",
+			new: "func (y *Y) C() {}\n\nfunc (x *Y) A() *elastic.X {\n\treturn nil\n}\n",
+		},
+		{
+			name: "value receiver stays value on copy",
+			modify: func(src *Source) {
+				src.CopyMethod(src.FindMethod("X", "B"), "Y")
+			},
+			old: "func (y *Y) C() {}\n",
+			new: "func (y *Y) C() {}\n\nfunc (x Y) B(v elastic.X, m map[string]*elastic.X) ([]elastic.X, error) {\n\treturn nil, nil\n}\n",
+		},
+		{
+			name: "method is removed",
+			modify: func(src *Source) {
+				src.RemoveMethod("X", "A")
+			},
+			old: "func (x *X) A() *elastic.X {\n\treturn nil\n}\n\n",
+			new: "",
+		},
+		{
+			name: "missing method is not removed",
+			modify: func(src *Source) {
+				src.RemoveMethod("Y", "A")
+			},
+		},
+		{
+			name: "result type is replaced",
+			modify: func(src *Source) {
+				src.FindMethod("X", "A").ReplaceResultType("elastic.X", "X")
+			},
+			old: "func (x *X) A() *elastic.X {",
+			new: "func (x *X) A() *X {",
+		},
+		{
+			name: "parameter types are kept by result replace",
+			modify: func(src *Source) {
+				src.FindMethod("X", "B").ReplaceResultType("elastic.X", "X")
+			},
+			old: "([]elastic.X, error)",
+			new: "([]X, error)",
+		},
+		{
+			name: "signature types are replaced",
+			modify: func(src *Source) {
+				src.FindMethod("X", "B").ReplaceSignatureType("elastic.X", "X")
+			},
+			old: "func (x X) B(v elastic.X, m map[string]*elastic.X) ([]elastic.X, error) {",
+			new: "func (x X) B(v X, m map[string]*X) ([]X, error) {",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader(methodsSource))
+			test.modify(src)
+			expected := formatCode(t, strings.Replace(methodsSource, test.old, test.new, 1))
+			if actual := formatSource(t, src); actual != expected {
+				t.Errorf("unexpected output:\n%s", actual)
+			}
+		})
+	}
+}
+
+func TestFunctionReplaceTypeCount(t *testing.T) {
+	src := NewDst(strings.NewReader(methodsSource))
+	method := src.FindMethod("X", "B")
+	if replaced := method.ReplaceResultType("elastic.Y", "Y"); replaced != 0 {
+		t.Errorf("expected no replaced types, got %d", replaced)
+	}
+	if replaced := method.ReplaceSignatureType("elastic.X", "X"); replaced != 3 {
+		t.Errorf("expected 3 replaced types, got %d", replaced)
+	}
+	if replaced := src.FindMethod("Y", "C").ReplaceResultType("elastic.X", "X"); replaced != 0 {
+		t.Errorf("expected no replaced types in function without results, got %d", replaced)
+	}
+}
@@ -0,0 +1,249 @@
+package pretty_dst
+
+import (
+	"bytes"
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"gopkg.in/src-d/go-billy.v4"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//Container for all go files of one directory parsed into shared fileset.
+//Files are keyed by their path in filesystem
+type Package struct {
+	FS      billy.Filesystem
+	Dir     string
+	FileSet *token.FileSet
+
+	//optional, used to resolve imported packages during type checking
+	Importer types.Importer
+
+	files    map[string]*Source
+	rendered map[string][]byte
+	removed  map[string]bool
+}
+
+//Creates new empty Package for directory, call Load to parse its files
+func NewPackage(fs billy.Filesystem, dir string) *Package {
+	return &Package{
+		FS:       fs,
+		Dir:      dir,
+		FileSet:  token.NewFileSet(),
+		files:    map[string]*Source{},
+		rendered: map[string][]byte{},
+		removed:  map[string]bool{},
+	}
+}
+
+//Loads go files of directory which are not loaded yet
+func (pkg *Package) Load() error {
+	entries, err := pkg.FS.ReadDir(pkg.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		filename := pkg.Dir + entry.Name()
+		if _, loaded := pkg.files[filename]; loaded || pkg.removed[filename] || entry.IsDir() || !strings.HasSuffix(filename, ".go") {
+			continue
+		}
+		if err := pkg.loadFile(filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pkg *Package) loadFile(filename string) error {
+	file, err := pkg.FS.Open(filename)
+	if err != nil {
+		return err
+	}
+	src := NewNamedDst(pkg.FileSet, filename, file)
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	//output is compared with initial output on save, so unchanged files are not written
+	buffer := &bytes.Buffer{}
+	if err := src.Save(buffer); err != nil {
+		return err
+	}
+	pkg.files[filename] = src
+	pkg.rendered[filename] = buffer.Bytes()
+	return nil
+}
+
+//Returns sorted paths of loaded files
+func (pkg *Package) Filenames() []string {
+	filenames := []string{}
+	for filename := range pkg.files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+//Returns loaded file or nil
+func (pkg *Package) File(filename string) *Source {
+	return pkg.files[filename]
+}
+
+//Forgets file, so it is loaded again by next Load. Changes of file are dropped
+func (pkg *Package) Unload(filename string) {
+	delete(pkg.files, filename)
+	delete(pkg.rendered, filename)
+}
+
+//Moves file to other path, old file is removed on save
+func (pkg *Package) RenameFile(from string, to string) {
+	src, ok := pkg.files[from]
+	if !ok || from == to {
+		return
+	}
+	pkg.RemoveFile(from)
+	pkg.files[to] = src
+	delete(pkg.removed, to)
+}
+
+//Removes file from package, it is removed from filesystem on save
+func (pkg *Package) RemoveFile(filename string) {
+	if _, ok := pkg.files[filename]; !ok {
+		return
+	}
+	delete(pkg.files, filename)
+	delete(pkg.rendered, filename)
+	pkg.removed[filename] = true
+}
+
+//Returns paths of files which output differs from loaded one
+func (pkg *Package) Changed() []string {
+	changed := []string{}
+	for _, filename := range pkg.Filenames() {
+		buffer := &bytes.Buffer{}
+		if err := pkg.files[filename].Save(buffer); err != nil || !bytes.Equal(buffer.Bytes(), pkg.rendered[filename]) {
+			changed = append(changed, filename)
+		}
+	}
+	return changed
+}
+
+//Saves changed files and removes deleted ones
+func (pkg *Package) Save() error {
+	for _, filename := range pkg.Changed() {
+		buffer := &bytes.Buffer{}
+		if err := pkg.files[filename].Save(buffer); err != nil {
+			return err
+		}
+		file, err := pkg.FS.Create(filename)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(buffer.Bytes()); err != nil {
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		pkg.rendered[filename] = buffer.Bytes()
+	}
+
+	for filename := range pkg.removed {
+		if _, err := pkg.FS.Stat(filename); err == nil {
+			if err := pkg.FS.Remove(filename); err != nil {
+				return err
+			}
+		}
+		delete(pkg.removed, filename)
+	}
+	return nil
+}
+
+//Restores ast of all files into single fileset, so package can be type-checked.
+//Restorer maps ast nodes back to dst
+func (pkg *Package) Restore() (*decorator.Restorer, map[string]*ast.File) {
+	restorer := decorator.NewRestorer()
+	files := map[string]*ast.File{}
+	for _, filename := range pkg.Filenames() {
+		files[filename] = pkg.files[filename].RestoreWith(restorer, filename)
+	}
+	return restorer, files
+}
+
+//Returns path of file which contains node or empty string
+func (pkg *Package) FileOf(node dst.Node) string {
+	for _, filename := range pkg.Filenames() {
+		found := false
+		dst.Inspect(pkg.files[filename].Dst, func(n dst.Node) bool {
+			found = found || n == node
+			return !found
+		})
+		if found {
+			return filename
+		}
+	}
+	return ""
+}
+
+//Find structure by name pattern in any file
+func (pkg *Package) FindStructure(pattern string) *StructureDeclaration {
+	for _, filename := range pkg.Filenames() {
+		if structure := pkg.files[filename].FindStructure(pattern); structure != nil {
+			return structure
+		}
+	}
+	return nil
+}
+
+//Find all structures matching name pattern in all files
+func (pkg *Package) FindStructures(pattern string) []*StructureDeclaration {
+	structures := []*StructureDeclaration{}
+	for _, filename := range pkg.Filenames() {
+		structures = append(structures, pkg.files[filename].FindStructures(pattern)...)
+	}
+	return structures
+}
+
+//Find all functions and methods matching name pattern in all files
+func (pkg *Package) FindFunctions(pattern string) []*Function {
+	functions := []*Function{}
+	for _, filename := range pkg.Filenames() {
+		functions = append(functions, pkg.files[filename].FindFunctions(pattern)...)
+	}
+	return functions
+}
+
+//Find functions and methods which call function or method matching name pattern
+func (pkg *Package) FindCallers(pattern string) []*Function {
+	regex, _ := regexp.Compile(pattern)
+	callers := []*Function{}
+	for _, function := range pkg.FindFunctions(".*") {
+		if function.Extract().Body != nil && callsFunction(function.Extract().Body, regex) {
+			callers = append(callers, function)
+		}
+	}
+	return callers
+}
+
+//checks if node contains call of function or method matching pattern
+func callsFunction(node dst.Node, pattern *regexp.Regexp) bool {
+	found := false
+	dst.Inspect(node, func(n dst.Node) bool {
+		call, ok := n.(*dst.CallExpr)
+		if !ok || found {
+			return !found
+		}
+		switch fun := call.Fun.(type) {
+		case *dst.Ident:
+			found = pattern.MatchString(fun.Name)
+		case *dst.SelectorExpr:
+			found = pattern.MatchString(fun.Sel.Name)
+		}
+		return !found
+	})
+	return found
+}
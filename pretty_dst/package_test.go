@@ -0,0 +1,158 @@
+package pretty_dst
+
+import (
+	"github.com/dave/dst"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"reflect"
+	"testing"
+)
+
+const testPackageDir = "pkg/"
+
+//creates package of in-memory files and loads it
+func loadTestPackage(t *testing.T, files map[string]string) *Package {
+	fs := memfs.New()
+	for name, content := range files {
+		file, err := fs.Create(testPackageDir + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkg := NewPackage(fs, testPackageDir)
+	if err := pkg.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+//returns names of functions in found order
+func functionNames(functions []*Function) []string {
+	names := []string{}
+	for _, function := range functions {
+		names = append(names, function.GetName())
+	}
+	return names
+}
+
+func TestPackageFindCallers(t *testing.T) {
+	pkg := loadTestPackage(t, map[string]string{
+		"a.go": "package a\n\nfunc target() {}\n\nfunc direct() {\n\ttarget()\n}\n\nfunc nested() {\n\tif true {\n\t\tfunc() { target() }()\n\t}\n}\n",
+		"b.go": "package a\n\ntype X struct{}\n\nfunc (x X) Target() {}\n\nfunc (x X) method() {\n\tx.Target()\n}\n\nfunc unrelated() {\n\tdirect()\n}\n",
+	})
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected []string
+	}{
+		{"function call", "^target$", []string{"direct", "nested"}},
+		{"method call", "^Target$", []string{"method"}},
+		{"calls in several files", "(?i)^target$", []string{"direct", "nested", "method"}},
+		{"no callers", "^unrelated$", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := functionNames(pkg.FindCallers(test.pattern)); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPackageFileOf(t *testing.T) {
+	pkg := loadTestPackage(t, map[string]string{
+		"a.go": "package a\n\ntype X struct{}\n",
+		"b.go": "package a\n\nfunc f() {\n\tg()\n}\n",
+	})
+
+	var call dst.Node
+	dst.Inspect(pkg.File(testPackageDir+"b.go").Dst, func(node dst.Node) bool {
+		if _, ok := node.(*dst.CallExpr); ok {
+			call = node
+		}
+		return true
+	})
+
+	tests := []struct {
+		name     string
+		node     dst.Node
+		expected string
+	}{
+		{"declaration", pkg.FindStructure("^X$").Extract(), testPackageDir + "a.go"},
+		{"nested node", call, testPackageDir + "b.go"},
+		{"node outside of package", NewIdent("x", nil), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := pkg.FileOf(test.node); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPackageChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(pkg *Package)
+		expected []string
+	}{
+		{
+			name:     "nothing changed",
+			modify:   func(pkg *Package) {},
+			expected: []string{},
+		},
+		{
+			name: "changed file",
+			modify: func(pkg *Package) {
+				pkg.FindStructure("^X$").AddField("a", "int")
+			},
+			expected: []string{testPackageDir + "a.go"},
+		},
+		{
+			name: "change which restores loaded output",
+			modify: func(pkg *Package) {
+				structure := pkg.FindStructure("^X$")
+				structure.Rename("Y")
+				structure.Rename("X")
+			},
+			expected: []string{},
+		},
+		{
+			name: "renamed file",
+			modify: func(pkg *Package) {
+				pkg.RenameFile(testPackageDir+"b.go", testPackageDir+"c.go")
+			},
+			expected: []string{testPackageDir + "c.go"},
+		},
+		{
+			name: "removed file",
+			modify: func(pkg *Package) {
+				pkg.FindFunctions("^f$")[0].Rename("g")
+				pkg.RemoveFile(testPackageDir + "b.go")
+			},
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pkg := loadTestPackage(t, map[string]string{
+				"a.go": "package a\n\ntype X struct{}\n",
+				"b.go": "package a\n\nfunc f() {}\n",
+			})
+			test.modify(pkg)
+			if actual := pkg.Changed(); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 type structureSearchQuery struct {
 	namePattern *regexp.Regexp
 	structure   *StructureDeclaration
+	structures  []*StructureDeclaration
 }
 
 //Creates new Structure search query
@@ -23,6 +24,7 @@ func (q *structureSearchQuery) Visit(node dst.Node) dst.Visitor {
 	structure, found := findStructureByPattern(node, q.namePattern)
 	if found {
 		q.structure = structure
+		q.structures = append(q.structures, structure)
 	}
 	return q
 }
@@ -46,6 +48,7 @@ func findStructureByPattern(node dst.Node, pattern *regexp.Regexp) (*StructureDe
 type functionSearchQuery struct {
 	namePattern *regexp.Regexp
 	function    *Function
+	functions   []*Function
 }
 
 //Creates new Function search query
@@ -60,6 +63,7 @@ func (q *functionSearchQuery) Visit(node dst.Node) dst.Visitor {
 	function, found := findFunctionByPattern(node, q.namePattern)
 	if found {
 		q.function = function
+		q.functions = append(q.functions, function)
 	}
 	return q
 }
@@ -0,0 +1,86 @@
+package pretty_dst
+
+import (
+	"github.com/dave/dst"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strings"
+)
+
+//Renames package-level declaration and every reference to it in all files (method receivers,
+//composite literals, calls, tests). References are resolved with go/types, so shadowing
+//local names and fields with the same name are kept. Comments mentioning the name are
+//changed only if withComments is set. Returns number of renamed identifiers
+func (pkg *Package) Rename(from string, to string, withComments bool) int {
+	restorer, files := pkg.Restore()
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+
+	//files can have different package names while package is being renamed,
+	//so names of restored copies are unified, otherwise checker skips them
+	astSet := []*ast.File{}
+	for _, filename := range pkg.Filenames() {
+		file := files[filename]
+		if strings.HasSuffix(file.Name.Name, "_test") {
+			continue
+		}
+		if len(astSet) > 0 {
+			file.Name.Name = astSet[0].Name.Name
+		}
+		astSet = append(astSet, file)
+	}
+
+	//package is usually incomplete during generation, errors don't prevent resolving of declarations
+	config := types.Config{
+		Importer: pkg.Importer,
+		Error:    func(error) {},
+	}
+	checked, _ := config.Check(pkg.Dir, restorer.Fset, astSet, info)
+	if checked == nil {
+		return 0
+	}
+	target := checked.Scope().Lookup(from)
+	if target == nil {
+		return 0
+	}
+
+	renamed := 0
+	for _, identifiers := range []map[*ast.Ident]types.Object{info.Defs, info.Uses} {
+		for ident, object := range identifiers {
+			if object != target {
+				continue
+			}
+			if node, ok := restorer.Dst.Nodes[ident].(*dst.Ident); ok {
+				node.Name = to
+				renamed++
+			}
+		}
+	}
+
+	if withComments {
+		for _, filename := range pkg.Filenames() {
+			renameInComments(pkg.files[filename].Dst, from, to)
+		}
+	}
+	return renamed
+}
+
+//replaces whole-word mentions of name in comments of all nodes
+func renameInComments(file *dst.File, from string, to string) {
+	pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(from) + `\b`)
+	dst.Inspect(file, func(node dst.Node) bool {
+		if node == nil {
+			return false
+		}
+		decorations := node.Decorations()
+		for _, comments := range []dst.Decorations{decorations.Start, decorations.End} {
+			for i, comment := range comments {
+				comments[i] = pattern.ReplaceAllString(comment, to)
+			}
+		}
+		return true
+	})
+}
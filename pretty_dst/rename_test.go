@@ -0,0 +1,93 @@
+package pretty_dst
+
+import (
+	"testing"
+)
+
+func TestPackageRename(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		withComments bool
+		expected     map[string]string
+		renamed      int
+	}{
+		{
+			name: "declaration, receivers, literals and calls",
+			files: map[string]string{
+				"a.go": "package a\n\n//X is renamed\ntype X struct{}\n\nfunc NewX() *X {\n\treturn &X{}\n}\n\nfunc (x *X) f() {}\n",
+			},
+			expected: map[string]string{
+				"a.go": "package a\n\n//X is renamed\ntype Y struct{}\n\nfunc NewX() *Y {\n\treturn &Y{}\n}\n\nfunc (x *Y) f() {}\n",
+			},
+			renamed: 4,
+		},
+		{
+			name: "shadowing local is kept",
+			files: map[string]string{
+				"a.go": "package a\n\nvar X = 1\n\nfunc f() int {\n\tX := 2\n\treturn X\n}\n\nfunc g() int {\n\treturn X\n}\n",
+			},
+			expected: map[string]string{
+				"a.go": "package a\n\nvar Y = 1\n\nfunc f() int {\n\tX := 2\n\treturn X\n}\n\nfunc g() int {\n\treturn Y\n}\n",
+			},
+			renamed: 2,
+		},
+		{
+			name: "field with the same name is kept",
+			files: map[string]string{
+				"a.go": "package a\n\ntype X struct{}\n\ntype Z struct {\n\tX *X\n}\n\nfunc f(z Z) *X {\n\treturn z.X\n}\n",
+			},
+			expected: map[string]string{
+				"a.go": "package a\n\ntype Y struct{}\n\ntype Z struct {\n\tX *Y\n}\n\nfunc f(z Z) *Y {\n\treturn z.X\n}\n",
+			},
+			renamed: 3,
+		},
+		{
+			name: "reference in test file",
+			files: map[string]string{
+				"a.go":      "package a\n\ntype X struct{}\n",
+				"a_test.go": "package a\n\nimport \"testing\"\n\nfunc TestX(t *testing.T) {\n\t_ = X{}\n}\n",
+			},
+			expected: map[string]string{
+				"a.go":      "package a\n\ntype Y struct{}\n",
+				"a_test.go": "package a\n\nimport \"testing\"\n\nfunc TestX(t *testing.T) {\n\t_ = Y{}\n}\n",
+			},
+			renamed: 2,
+		},
+		{
+			name: "comments are renamed on request",
+			files: map[string]string{
+				"a.go": "package a\n\n//X is renamed, XY is not\ntype X struct{}\n",
+			},
+			withComments: true,
+			expected: map[string]string{
+				"a.go": "package a\n\n//Y is renamed, XY is not\ntype Y struct{}\n",
+			},
+			renamed: 1,
+		},
+		{
+			name: "unknown name",
+			files: map[string]string{
+				"a.go": "package a\n\ntype Z struct{}\n",
+			},
+			expected: map[string]string{
+				"a.go": "package a\n\ntype Z struct{}\n",
+			},
+			renamed: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pkg := loadTestPackage(t, test.files)
+			if renamed := pkg.Rename("X", "Y", test.withComments); renamed != test.renamed {
+				t.Errorf("expected %d renamed identifiers, got %d", test.renamed, renamed)
+			}
+			for name, expected := range test.expected {
+				if actual := formatSource(t, pkg.File(testPackageDir+name)); actual != formatCode(t, expected) {
+					t.Errorf("unexpected output of %s:\n%s", name, actual)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package pretty_dst
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"go/printer"
+	"go/token"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+//fields which don't affect meaning of node and are ignored by matcher
+var ignoredNodeFields = map[string]bool{
+	"Decs":  true,
+	"Obj":   true,
+	"Path":  true,
+	"Scope": true,
+}
+
+//Rewrite rule in the gofmt -r manner: every expression or statement matching pattern is replaced.
+//Wildcards ($x) in pattern match any expression, the same wildcard used twice should match equal expressions.
+//Wildcards of replacement are filled with matched expressions:
+//	$x.subAggregations[$name] = $agg -> $x.Inject($name, $agg)
+type RewriteRule struct {
+	Pattern     string
+	Replacement string
+
+	//matches are reported to Output and nodes are not changed
+	DryRun bool
+	Output io.Writer
+
+	pattern     dst.Node
+	isStatement bool
+}
+
+//Creates rewrite rule. Pattern is parsed as expression, or as statement if it isn't expression
+func NewRewriteRule(pattern string, replacement string) (*RewriteRule, error) {
+	rule := &RewriteRule{
+		Pattern:     pattern,
+		Replacement: replacement,
+		Output:      os.Stdout,
+	}
+
+	source := replacePlaceholders(pattern)
+	if file, err := parseSnippet("package p\n\nvar _ = "+source, pattern); err == nil {
+		rule.pattern = file.Decls[0].(*dst.GenDecl).Specs[0].(*dst.ValueSpec).Values[0]
+	} else {
+		file, err := parseSnippet("package p\n\nfunc _() {\n"+source+"\n}", pattern)
+		if err != nil {
+			return nil, err
+		}
+		statements := file.Decls[0].(*dst.FuncDecl).Body.List
+		if len(statements) != 1 {
+			return nil, fmt.Errorf("pattern %q should be single expression or statement", pattern)
+		}
+		rule.pattern = statements[0]
+		rule.isStatement = true
+	}
+
+	if _, ok := placeholderName(exprOf(rule.pattern)); ok {
+		return nil, fmt.Errorf("pattern %q matches every expression", pattern)
+	}
+
+	//replacement can use only wildcards of pattern
+	wildcards := Placeholders{}
+	for _, match := range placeholder.FindAllStringSubmatch(pattern, -1) {
+		wildcards[match[1]] = match[1]
+	}
+	if _, err := rule.replacement(wildcards); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+//Applies rule to file. Returns number of matches
+func (rule *RewriteRule) Apply(src *Source) int {
+	return rule.apply(src, "")
+}
+
+//Applies rule to every file of package. Returns number of matches
+func (rule *RewriteRule) ApplyPackage(pkg *Package) int {
+	matches := 0
+	for _, filename := range pkg.Filenames() {
+		matches += rule.apply(pkg.File(filename), filename)
+	}
+	return matches
+}
+
+//replaces matches bottom-up, so wildcards capture already rewritten expressions
+func (rule *RewriteRule) apply(src *Source, filename string) int {
+	matches := 0
+	dstutil.Apply(src.Dst, nil, func(cursor *dstutil.Cursor) bool {
+		bindings := Placeholders{}
+		if cursor.Node() == nil || !matchNode(rule.pattern, cursor.Node(), bindings) {
+			return true
+		}
+		replacement, err := rule.replacement(bindings)
+		if err != nil || !fitsSlot(cursor, replacement) {
+			return true
+		}
+		matches++
+		if rule.DryRun {
+			rule.report(filename, cursor.Node(), replacement)
+			return true
+		}
+		*replacement.Decorations() = *cursor.Node().Decorations()
+		cursor.Replace(replacement)
+		return true
+	})
+	return matches
+}
+
+//checks if node can be placed at cursor position: expression can't replace
+//identifier in function name, selector or field names
+func fitsSlot(cursor *dstutil.Cursor, node dst.Node) bool {
+	parent := reflect.ValueOf(cursor.Parent())
+	if parent.Kind() != reflect.Ptr || parent.IsNil() {
+		return false
+	}
+	field := parent.Elem().FieldByName(cursor.Name())
+	if !field.IsValid() {
+		return false
+	}
+	slot := field.Type()
+	if cursor.Index() >= 0 {
+		slot = slot.Elem()
+	}
+	return reflect.TypeOf(node).AssignableTo(slot)
+}
+
+//returns expression of pattern, statement which consists of single expression is unwrapped
+func exprOf(node dst.Node) dst.Expr {
+	switch n := node.(type) {
+	case dst.Expr:
+		return n
+	case *dst.ExprStmt:
+		return n.X
+	}
+	return nil
+}
+
+//builds replacement node with wildcards filled with bindings
+func (rule *RewriteRule) replacement(bindings Placeholders) (dst.Node, error) {
+	if rule.isStatement {
+		return ParseStatement(rule.Replacement, bindings)
+	}
+	return ParseExpression(rule.Replacement, bindings)
+}
+
+//prints match and its replacement
+func (rule *RewriteRule) report(filename string, match dst.Node, replacement dst.Node) {
+	if filename != "" {
+		filename += ": "
+	}
+	fmt.Fprintf(rule.Output, "%s%s -> %s\n", filename, renderNode(match), renderNode(replacement))
+}
+
+//compares node with pattern ignoring comments and formatting. Wildcards are bound to matched expressions
+func matchNode(pattern dst.Node, node dst.Node, bindings Placeholders) bool {
+	return matchValue(reflect.ValueOf(pattern), reflect.ValueOf(node), bindings)
+}
+
+func matchValue(pattern reflect.Value, value reflect.Value, bindings Placeholders) bool {
+	if pattern.IsValid() != value.IsValid() {
+		return false
+	}
+	if !pattern.IsValid() {
+		return true
+	}
+	if pattern.Kind() == reflect.Interface {
+		if pattern.IsNil() || value.Kind() != reflect.Interface || value.IsNil() {
+			return pattern.IsNil() && value.Kind() == reflect.Interface && value.IsNil()
+		}
+		return matchValue(pattern.Elem(), value.Elem(), bindings)
+	}
+
+	//wildcard matches any expression, repeated wildcard matches equal expression
+	if ident, ok := pattern.Interface().(*dst.Ident); ok && ident != nil {
+		if name, ok := placeholderName(ident); ok {
+			expr, ok := value.Interface().(dst.Expr)
+			if !ok {
+				return false
+			}
+			if bound, ok := bindings[name]; ok {
+				return matchValue(reflect.ValueOf(bound), reflect.ValueOf(expr), Placeholders{})
+			}
+			bindings[name] = expr
+			return true
+		}
+	}
+
+	if pattern.Type() != value.Type() {
+		return false
+	}
+	switch pattern.Kind() {
+	case reflect.Ptr:
+		if pattern.IsNil() || value.IsNil() {
+			return pattern.IsNil() == value.IsNil()
+		}
+		return matchValue(pattern.Elem(), value.Elem(), bindings)
+	case reflect.Slice:
+		if pattern.Len() != value.Len() {
+			return false
+		}
+		for i := 0; i < pattern.Len(); i++ {
+			if !matchValue(pattern.Index(i), value.Index(i), bindings) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < pattern.NumField(); i++ {
+			if ignoredNodeFields[pattern.Type().Field(i).Name] {
+				continue
+			}
+			if !matchValue(pattern.Field(i), value.Field(i), bindings) {
+				return false
+			}
+		}
+		return true
+	}
+	return pattern.Interface() == value.Interface()
+}
+
+//returns Go source of expression or statement
+func renderNode(node dst.Node) string {
+	clone := dst.Clone(node)
+	file := &dst.File{Name: NewIdent("p", nil)}
+	switch n := clone.(type) {
+	case dst.Expr:
+		file.Decls = []dst.Decl{&dst.GenDecl{
+			Tok:   token.VAR,
+			Specs: []dst.Spec{&dst.ValueSpec{Names: []*dst.Ident{NewIdent("_", nil)}, Values: []dst.Expr{n}}},
+		}}
+	case dst.Stmt:
+		file.Decls = []dst.Decl{&dst.FuncDecl{
+			Name: NewIdent("_", nil),
+			Type: &dst.FuncType{Func: true, Params: &dst.FieldList{}},
+			Body: &dst.BlockStmt{List: []dst.Stmt{n}},
+		}}
+	default:
+		return ""
+	}
+
+	restorer := decorator.NewRestorer()
+	if _, err := restorer.RestoreFile(file); err != nil {
+		return ""
+	}
+	buffer := &bytes.Buffer{}
+	if err := printer.Fprint(buffer, restorer.Fset, restorer.Ast.Nodes[clone]); err != nil {
+		return ""
+	}
+	return strings.Replace(buffer.String(), "\n", " ", -1)
+}
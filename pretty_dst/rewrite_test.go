@@ -0,0 +1,149 @@
+package pretty_dst
+
+import (
+	"bytes"
+	"go/format"
+	"strings"
+	"testing"
+)
+
+//renders source formatted with gofmt, so tests don't depend on printer alignment
+func formatSource(t *testing.T, src *Source) string {
+	buffer := &bytes.Buffer{}
+	if err := src.Save(buffer); err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := format.Source(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(formatted)
+}
+
+func formatCode(t *testing.T, code string) string {
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(formatted)
+}
+
+func TestRewriteRuleApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		replacement string
+		source      string
+		expected    string
+		matches     int
+	}{
+		{
+			name:        "statement",
+			pattern:     "$x.subAggregations[$name] = $agg",
+			replacement: "$x.Inject($name, $agg)",
+			source:      "package a\n\nfunc f() {\n\ta.subAggregations[name] = agg\n\ta.subAggregations = nil\n}\n",
+			expected:    "package a\n\nfunc f() {\n\ta.Inject(name, agg)\n\ta.subAggregations = nil\n}\n",
+			matches:     1,
+		},
+		{
+			name:        "repeated wildcard",
+			pattern:     "$a + $a",
+			replacement: "2 * $a",
+			source:      "package a\n\nvar x = y + y\nvar z = y + w\n",
+			expected:    "package a\n\nvar x = 2 * y\nvar z = y + w\n",
+			matches:     1,
+		},
+		{
+			name:        "nested matches are rewritten bottom-up",
+			pattern:     "$x.subAggregations[$n]",
+			replacement: "$x.Get($n)",
+			source:      "package a\n\nvar x = a.subAggregations[b.subAggregations[\"c\"]]\n",
+			expected:    "package a\n\nvar x = a.Get(b.Get(\"c\"))\n",
+			matches:     2,
+		},
+		{
+			name:        "identifier slots are skipped",
+			pattern:     "foo",
+			replacement: "bar.Foo",
+			source:      "package a\n\nfunc foo() {}\n\nvar x = foo\nvar y = z.foo\n",
+			expected:    "package a\n\nfunc foo() {}\n\nvar x = bar.Foo\nvar y = z.foo\n",
+			matches:     1,
+		},
+		{
+			name:        "comments are kept",
+			pattern:     "$x.subAggregations[$name] = $agg",
+			replacement: "$x.Inject($name, $agg)",
+			source:      "package a\n\nfunc f() {\n\t// inject\n\ta.subAggregations[name] = agg // end\n}\n",
+			expected:    "package a\n\nfunc f() {\n\t// inject\n\ta.Inject(name, agg) // end\n}\n",
+			matches:     1,
+		},
+		{
+			name:        "no matches",
+			pattern:     "$a - $a",
+			replacement: "0",
+			source:      "package a\n\nvar x = y - z\n",
+			expected:    "package a\n\nvar x = y - z\n",
+			matches:     0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := NewRewriteRule(test.pattern, test.replacement)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src := NewDst(strings.NewReader(test.source))
+			if matches := rule.Apply(src); matches != test.matches {
+				t.Errorf("expected %d matches, got %d", test.matches, matches)
+			}
+			if actual := formatSource(t, src); actual != formatCode(t, test.expected) {
+				t.Errorf("unexpected output:\n%s", actual)
+			}
+		})
+	}
+}
+
+func TestRewriteRuleDryRun(t *testing.T) {
+	rule, err := NewRewriteRule("$a + $a", "2 * $a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	rule.DryRun = true
+	rule.Output = output
+
+	source := "package a\n\nvar x = y + y\n"
+	src := NewDst(strings.NewReader(source))
+	if matches := rule.Apply(src); matches != 1 {
+		t.Errorf("expected 1 match, got %d", matches)
+	}
+	if output.String() != "y + y -> 2 * y\n" {
+		t.Errorf("unexpected report %q", output.String())
+	}
+	if actual := formatSource(t, src); actual != formatCode(t, source) {
+		t.Errorf("dry run changed source:\n%s", actual)
+	}
+}
+
+func TestNewRewriteRuleErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		replacement string
+	}{
+		{"bare wildcard", "$x", "y"},
+		{"unknown wildcard in replacement", "$a + $b", "$c"},
+		{"broken pattern", "a +", "b"},
+		{"several statements", "a = 1; b = 2", "c = 3"},
+		{"expression replaced with statement", "a + b", "c = 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewRewriteRule(test.pattern, test.replacement); err == nil {
+				t.Errorf("expected error for %q -> %q", test.pattern, test.replacement)
+			}
+		})
+	}
+}
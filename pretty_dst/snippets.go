@@ -0,0 +1,212 @@
+package pretty_dst
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+//named placeholder in snippet ($recv)
+var placeholder = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+//placeholders are replaced with identifiers with this prefix before parsing
+const placeholderPrefix = "__placeholder_"
+
+//Values of snippet placeholders. Value can be:
+//string - identifier or Go source of expression,
+//dst.Expr - expression (copied for every use),
+//dst.Stmt - statement, only if placeholder is used as statement ($init)
+type Placeholders map[string]interface{}
+
+//parses expression snippet ("newInjectable($recv)") and fills placeholders
+func ParseExpression(snippet string, values Placeholders) (dst.Expr, error) {
+	file, err := parseSnippet("package p\n\nvar _ = "+replacePlaceholders(snippet), snippet)
+	if err != nil {
+		return nil, err
+	}
+	expr := file.Decls[0].(*dst.GenDecl).Specs[0].(*dst.ValueSpec).Values[0]
+	filled, err := fillPlaceholders(expr, values)
+	if err != nil {
+		return nil, err
+	}
+	return filled.(dst.Expr), nil
+}
+
+//parses statements snippet ("a.Injectable = newInjectable($recv)"), one statement per line or separated by semicolon
+func ParseStatements(snippet string, values Placeholders) ([]dst.Stmt, error) {
+	file, err := parseSnippet("package p\n\nfunc _() {\n"+replacePlaceholders(snippet)+"\n}", snippet)
+	if err != nil {
+		return nil, err
+	}
+	body, err := fillPlaceholders(file.Decls[0].(*dst.FuncDecl).Body, values)
+	if err != nil {
+		return nil, err
+	}
+	return body.(*dst.BlockStmt).List, nil
+}
+
+//parses snippet which contains exactly one statement
+func ParseStatement(snippet string, values Placeholders) (dst.Stmt, error) {
+	statements, err := ParseStatements(snippet, values)
+	if err != nil {
+		return nil, err
+	}
+	if len(statements) != 1 {
+		return nil, fmt.Errorf("snippet %q contains %d statements, expected one", snippet, len(statements))
+	}
+	return statements[0], nil
+}
+
+//parses top-level declarations snippet ("func (a *$type) Meta() {...}"). Comments are kept
+func ParseDeclarations(snippet string, values Placeholders) ([]dst.Decl, error) {
+	file, err := parseSnippet("package p\n\n"+replacePlaceholders(snippet), snippet)
+	if err != nil {
+		return nil, err
+	}
+	decls := []dst.Decl{}
+	for _, decl := range file.Decls {
+		filled, err := fillPlaceholders(decl, values)
+		if err != nil {
+			return nil, err
+		}
+		decls = append(decls, filled.(dst.Decl))
+	}
+	return decls, nil
+}
+
+func parseSnippet(source string, snippet string) (*dst.File, error) {
+	file, err := decorator.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("snippet %q can't be parsed: %s", snippet, err)
+	}
+	return file, nil
+}
+
+//replaces $name with identifier which can be parsed. Only placeholders in identifier position
+//are replaced, string literals and comments are kept as is
+func replacePlaceholders(snippet string) string {
+	fileSet := token.NewFileSet()
+	file := fileSet.AddFile("", fileSet.Base(), len(snippet))
+	s := scanner.Scanner{}
+	s.Init(file, []byte(snippet), nil, scanner.ScanComments)
+
+	result := &bytes.Buffer{}
+	last := 0
+	dollar := -1
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		offset := file.Offset(pos)
+		//placeholder name can be keyword ($type)
+		if (tok == token.IDENT || tok.IsKeyword()) && dollar >= 0 && offset == dollar+1 {
+			result.WriteString(snippet[last:dollar] + placeholderPrefix)
+			last = offset
+		}
+		dollar = -1
+		if tok == token.ILLEGAL && lit == "$" {
+			dollar = offset
+		}
+	}
+	result.WriteString(snippet[last:])
+	return result.String()
+}
+
+//replaces placeholder identifiers of parsed snippet with values
+func fillPlaceholders(node dst.Node, values Placeholders) (filled dst.Node, err error) {
+	//cursor panics if value doesn't fit into position (expression as function name)
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("placeholder value doesn't fit into snippet: %v", recovered)
+		}
+	}()
+
+	filled = dstutil.Apply(node, func(cursor *dstutil.Cursor) bool {
+		if err != nil {
+			return false
+		}
+		switch current := cursor.Node().(type) {
+		case *dst.ExprStmt:
+			name, ok := placeholderName(current.X)
+			if statement, isStatement := values[name].(dst.Stmt); ok && isStatement {
+				cursor.Replace(dst.Clone(statement))
+				return false
+			}
+		case *dst.Ident:
+			name, ok := placeholderName(current)
+			if !ok {
+				return true
+			}
+			value, found := values[name]
+			if !found {
+				err = fmt.Errorf("placeholder $%s has no value", name)
+				return false
+			}
+			var replacement dst.Node
+			replacement, err = placeholderValue(current, value)
+			if err == nil && replacement != current {
+				cursor.Replace(replacement)
+			}
+		}
+		return true
+	}, nil)
+	if err == nil {
+		fillCommentPlaceholders(filled, values)
+	}
+	return filled, err
+}
+
+//replaces placeholders in comments with identifier values
+func fillCommentPlaceholders(node dst.Node, values Placeholders) {
+	dst.Inspect(node, func(n dst.Node) bool {
+		if n == nil {
+			return false
+		}
+		decorations := n.Decorations()
+		for _, comments := range []dst.Decorations{decorations.Start, decorations.End} {
+			for i, comment := range comments {
+				comments[i] = placeholder.ReplaceAllStringFunc(strings.Replace(comment, placeholderPrefix, "$", -1), func(match string) string {
+					if value, ok := values[match[1:]].(string); ok {
+						return value
+					}
+					return match
+				})
+			}
+		}
+		return true
+	})
+}
+
+//returns placeholder name if expression is placeholder identifier
+func placeholderName(expr dst.Expr) (string, bool) {
+	ident, ok := expr.(*dst.Ident)
+	if !ok || !strings.HasPrefix(ident.Name, placeholderPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(ident.Name, placeholderPrefix), true
+}
+
+//converts placeholder value to node. Identifiers are renamed in place, so they can be used in any position
+func placeholderValue(ident *dst.Ident, value interface{}) (dst.Node, error) {
+	switch v := value.(type) {
+	case string:
+		if identifier.MatchString(v) {
+			ident.Name = v
+			return ident, nil
+		}
+		return ParseExpression(v, nil)
+	case *dst.Ident:
+		ident.Name = v.Name
+		return ident, nil
+	case dst.Expr:
+		return dst.Clone(v), nil
+	}
+	return nil, fmt.Errorf("unsupported value %T of placeholder %s", value, strings.TrimPrefix(ident.Name, placeholderPrefix))
+}
@@ -0,0 +1,172 @@
+package pretty_dst
+
+import (
+	"github.com/dave/dst"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  string
+		values   Placeholders
+		expected string
+	}{
+		{
+			name:     "identifier",
+			snippet:  "newInjectable($recv)",
+			values:   Placeholders{"recv": "a"},
+			expected: "newInjectable(a)",
+		},
+		{
+			name:     "expression source",
+			snippet:  "$x + 1",
+			values:   Placeholders{"x": "a.b"},
+			expected: "a.b + 1",
+		},
+		{
+			name:     "expression node",
+			snippet:  "f($x, $x)",
+			values:   Placeholders{"x": &dst.BasicLit{Value: "1"}},
+			expected: "f(1, 1)",
+		},
+		{
+			name:     "selector part",
+			snippet:  "a.$field",
+			values:   Placeholders{"field": "Injectable"},
+			expected: "a.Injectable",
+		},
+		{
+			name:     "string literal is kept",
+			snippet:  `f("$recv", $recv)`,
+			values:   Placeholders{"recv": "a"},
+			expected: `f("$recv", a)`,
+		},
+		{
+			name:     "raw string literal is kept",
+			snippet:  "f(`$recv`)",
+			values:   Placeholders{},
+			expected: "f(`$recv`)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := ParseExpression(test.snippet, test.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := renderNode(expr); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  string
+		values   Placeholders
+		expected []string
+	}{
+		{
+			name:     "single statement",
+			snippet:  "$recv.Injectable = newInjectable($recv)",
+			values:   Placeholders{"recv": "a"},
+			expected: []string{"a.Injectable = newInjectable(a)"},
+		},
+		{
+			name:     "statements separated by semicolon",
+			snippet:  "a := $x; b := a",
+			values:   Placeholders{"x": "1"},
+			expected: []string{"a := 1", "b := a"},
+		},
+		{
+			name:     "statement placeholder",
+			snippet:  "$init\nreturn a",
+			values:   Placeholders{"init": &dst.IncDecStmt{X: NewIdent("a", nil), Tok: token.INC}},
+			expected: []string{"a++", "return a"},
+		},
+		{
+			name:     "string literal is kept",
+			snippet:  `fmt.Println("cost: $10", $x)`,
+			values:   Placeholders{"x": "a"},
+			expected: []string{`fmt.Println("cost: $10", a)`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statements, err := ParseStatements(test.snippet, test.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(statements) != len(test.expected) {
+				t.Fatalf("expected %d statements, got %d", len(test.expected), len(statements))
+			}
+			for i, statement := range statements {
+				if actual := renderNode(statement); actual != test.expected[i] {
+					t.Errorf("expected %q, got %q", test.expected[i], actual)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDeclarations(t *testing.T) {
+	snippet := "//Meta of $type\nfunc (a *$type) Meta() string {\n\treturn \"$type\"\n}\n"
+	decls, err := ParseDeclarations(snippet, Placeholders{"type": "TermsAggregation"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := NewDst(strings.NewReader("package p\n"))
+	src.Dst.Decls = append(src.Dst.Decls, decls...)
+
+	expected := "package p\n\n//Meta of TermsAggregation\nfunc (a *TermsAggregation) Meta() string {\n\treturn \"$type\"\n}\n"
+	if actual := formatSource(t, src); actual != formatCode(t, expected) {
+		t.Errorf("unexpected output:\n%s", actual)
+	}
+}
+
+func TestParseSnippetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func() error
+	}{
+		{"placeholder without value", func() error {
+			_, err := ParseExpression("f($x)", Placeholders{})
+			return err
+		}},
+		{"broken snippet", func() error {
+			_, err := ParseExpression("f(", Placeholders{})
+			return err
+		}},
+		{"broken expression value", func() error {
+			_, err := ParseExpression("f($x)", Placeholders{"x": "a +"})
+			return err
+		}},
+		{"unsupported value", func() error {
+			_, err := ParseExpression("f($x)", Placeholders{"x": 1})
+			return err
+		}},
+		{"expression in identifier position", func() error {
+			_, err := ParseDeclarations("func $name() {}", Placeholders{"name": "a.b"})
+			return err
+		}},
+		{"several statements", func() error {
+			_, err := ParseStatement("a := 1; b := 2", Placeholders{})
+			return err
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.parse(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
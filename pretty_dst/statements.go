@@ -0,0 +1,227 @@
+package pretty_dst
+
+import (
+	"github.com/dave/dst"
+	"go/token"
+	"regexp"
+)
+
+//Predicate for statement search
+type StatementPredicate func(dst.Stmt) bool
+
+//returns first statement matching predicate. Nested blocks (if/for/switch/select) are searched too,
+//statements are visited in source order
+func (body *FunctionBody) FindStatement(predicate StatementPredicate) dst.Stmt {
+	if found := body.FindStatements(predicate); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+//returns all statements matching predicate including ones in nested blocks.
+//Bodies of function literals are not searched
+func (body *FunctionBody) FindStatements(predicate StatementPredicate) []dst.Stmt {
+	found := []dst.Stmt{}
+	walkStatements(&body.List, func(list *[]dst.Stmt, index int) {
+		if predicate((*list)[index]) {
+			found = append(found, (*list)[index])
+		}
+	})
+	return found
+}
+
+//returns last top-level return statement or nil
+func (body *FunctionBody) GetLastReturn() *ReturnStatement {
+	for i := len(body.List) - 1; i >= 0; i-- {
+		if statement, ok := body.List[i].(*dst.ReturnStmt); ok {
+			return DecorateReturnStatement(statement)
+		}
+	}
+	return nil
+}
+
+//inserts statements before target. Returns false if target is not in body
+func (body *FunctionBody) InsertBefore(target dst.Stmt, statements ...dst.Stmt) bool {
+	list, index := body.locate(target)
+	if list == nil {
+		return false
+	}
+	*list = spliceStatements(*list, index, index, statements)
+	return true
+}
+
+//inserts statements after target. Returns false if target is not in body
+func (body *FunctionBody) InsertAfter(target dst.Stmt, statements ...dst.Stmt) bool {
+	list, index := body.locate(target)
+	if list == nil {
+		return false
+	}
+	*list = spliceStatements(*list, index+1, index+1, statements)
+	return true
+}
+
+//replaces target with statements. Comments of target are moved to the first statement.
+//Returns false if target is not in body
+func (body *FunctionBody) Replace(target dst.Stmt, statements ...dst.Stmt) bool {
+	list, index := body.locate(target)
+	if list == nil {
+		return false
+	}
+	if len(statements) > 0 {
+		*statements[0].Decorations() = *target.Decorations()
+	}
+	*list = spliceStatements(*list, index, index+1, statements)
+	return true
+}
+
+//removes target statement. Returns false if target is not in body
+func (body *FunctionBody) Remove(target dst.Stmt) bool {
+	return body.Replace(target)
+}
+
+//returns statement list which contains target and its index
+func (body *FunctionBody) locate(target dst.Stmt) (*[]dst.Stmt, int) {
+	var found *[]dst.Stmt
+	position := -1
+	walkStatements(&body.List, func(list *[]dst.Stmt, index int) {
+		if found == nil && (*list)[index] == target {
+			found, position = list, index
+		}
+	})
+	return found, position
+}
+
+//calls visitor for every statement of list and its nested blocks in source order
+func walkStatements(list *[]dst.Stmt, visitor func(list *[]dst.Stmt, index int)) {
+	for index := range *list {
+		visitor(list, index)
+		for _, nested := range nestedStatements((*list)[index]) {
+			walkStatements(nested, visitor)
+		}
+	}
+}
+
+//returns statement lists of blocks directly nested in statement
+func nestedStatements(statement dst.Stmt) []*[]dst.Stmt {
+	lists := []*[]dst.Stmt{}
+	dst.Inspect(statement, func(node dst.Node) bool {
+		switch n := node.(type) {
+		case *dst.FuncLit:
+			return false
+		case *dst.BlockStmt:
+			lists = append(lists, &n.List)
+			return false
+		case *dst.CaseClause:
+			lists = append(lists, &n.Body)
+			return false
+		case *dst.CommClause:
+			lists = append(lists, &n.Body)
+			return false
+		}
+		return true
+	})
+	return lists
+}
+
+//replaces list[from:to] with statements
+func spliceStatements(list []dst.Stmt, from int, to int, statements []dst.Stmt) []dst.Stmt {
+	result := append([]dst.Stmt{}, list[:from]...)
+	result = append(result, statements...)
+	return append(result, list[to:]...)
+}
+
+//matches return statements
+func IsReturn(statement dst.Stmt) bool {
+	_, ok := statement.(*dst.ReturnStmt)
+	return ok
+}
+
+//matches assignments to variable or field (a, a.Injectable)
+func IsAssignmentTo(name string) StatementPredicate {
+	return func(statement dst.Stmt) bool {
+		assignment, ok := statement.(*dst.AssignStmt)
+		if !ok {
+			return false
+		}
+		for _, lhs := range assignment.Lhs {
+			if typeName(lhs) == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//matches var declarations of variable (var a = &X{})
+func IsDeclarationOf(name string) StatementPredicate {
+	return func(statement dst.Stmt) bool {
+		return declaredSpec(statement, name) != nil
+	}
+}
+
+//returns initial value of variable declared or assigned by statement (a := &X{}, var a = &X{}) or nil
+func InitialValue(statement dst.Stmt, name string) dst.Expr {
+	if assignment, ok := statement.(*dst.AssignStmt); ok && len(assignment.Lhs) == len(assignment.Rhs) {
+		for i, lhs := range assignment.Lhs {
+			if typeName(lhs) == name {
+				return assignment.Rhs[i]
+			}
+		}
+		return nil
+	}
+	return declaredValue(statement, name)
+}
+
+//returns value of variable in var declaration statement or nil
+func declaredValue(statement dst.Stmt, name string) dst.Expr {
+	spec := declaredSpec(statement, name)
+	if spec == nil || len(spec.Values) != len(spec.Names) {
+		return nil
+	}
+	for i, ident := range spec.Names {
+		if ident.Name == name {
+			return spec.Values[i]
+		}
+	}
+	return nil
+}
+
+//returns var spec which declares variable or nil
+func declaredSpec(statement dst.Stmt, name string) *dst.ValueSpec {
+	declaration, ok := statement.(*dst.DeclStmt)
+	if !ok {
+		return nil
+	}
+	genDecl, ok := declaration.Decl.(*dst.GenDecl)
+	if !ok || genDecl.Tok != token.VAR {
+		return nil
+	}
+	for _, spec := range genDecl.Specs {
+		valueSpec := spec.(*dst.ValueSpec)
+		for _, ident := range valueSpec.Names {
+			if ident.Name == name {
+				return valueSpec
+			}
+		}
+	}
+	return nil
+}
+
+//matches statements which call function or method matching name pattern
+func IsCallTo(pattern string) StatementPredicate {
+	regex, _ := regexp.Compile(pattern)
+	return func(statement dst.Stmt) bool {
+		return callsFunction(shallowStatement(statement), regex)
+	}
+}
+
+//returns statement without nested blocks, so call in if body doesn't match the if statement itself
+func shallowStatement(statement dst.Stmt) dst.Node {
+	switch s := statement.(type) {
+	case *dst.ExprStmt, *dst.AssignStmt, *dst.ReturnStmt, *dst.DeferStmt, *dst.GoStmt, *dst.SendStmt, *dst.DeclStmt:
+		return s
+	case *dst.IfStmt:
+		return &dst.IfStmt{Init: s.Init, Cond: s.Cond, Body: &dst.BlockStmt{}}
+	}
+	return &dst.EmptyStmt{}
+}
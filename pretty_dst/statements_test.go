@@ -0,0 +1,178 @@
+package pretty_dst
+
+import (
+	"github.com/dave/dst"
+	"strings"
+	"testing"
+)
+
+const statementsSource = `package a
+
+func f(x int) int {
+	a := 1
+	if x > 0 {
+		b := 2
+		for i := 0; i < x; i++ {
+			c := 3
+		}
+	}
+	switch x {
+	case 1:
+		//comment of d
+		d := 4
+	default:
+		e := 5
+	}
+	go func() {
+		g := 6
+	}()
+	return a
+}
+`
+
+func TestFunctionBodyFindStatement(t *testing.T) {
+	tests := []struct {
+		name      string
+		predicate StatementPredicate
+		expected  string
+	}{
+		{"top-level statement", IsAssignmentTo("a"), "a := 1"},
+		{"statement in if block", IsAssignmentTo("b"), "b := 2"},
+		{"statement in nested for block", IsAssignmentTo("c"), "c := 3"},
+		{"statement in case clause", IsAssignmentTo("d"), "d := 4"},
+		{"statement in default clause", IsAssignmentTo("e"), "e := 5"},
+		{"first match in source order", func(statement dst.Stmt) bool {
+			_, ok := statement.(*dst.AssignStmt)
+			return ok
+		}, "a := 1"},
+		{"function literal body is skipped", IsAssignmentTo("g"), ""},
+		{"no match", IsAssignmentTo("z"), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader(statementsSource))
+			statement := src.FindFunction("^f$").GetBody().FindStatement(test.predicate)
+			actual := ""
+			if statement != nil {
+				actual = renderNode(statement)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFunctionBodyEditing(t *testing.T) {
+	insertBefore := func(body *FunctionBody, target dst.Stmt, statement dst.Stmt) bool {
+		return body.InsertBefore(target, statement)
+	}
+	insertAfter := func(body *FunctionBody, target dst.Stmt, statement dst.Stmt) bool {
+		return body.InsertAfter(target, statement)
+	}
+	replace := func(body *FunctionBody, target dst.Stmt, statement dst.Stmt) bool {
+		return body.Replace(target, statement)
+	}
+	remove := func(body *FunctionBody, target dst.Stmt, statement dst.Stmt) bool {
+		return body.Remove(target)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		edit   func(body *FunctionBody, target dst.Stmt, statement dst.Stmt) bool
+		old    string
+		new    string
+	}{
+		{
+			name:   "insert before top-level statement",
+			target: "a",
+			edit:   insertBefore,
+			old:    "\ta := 1\n",
+			new:    "\tz := 0\n\ta := 1\n",
+		},
+		{
+			name:   "insert after statement in nested for block",
+			target: "c",
+			edit:   insertAfter,
+			old:    "\t\t\tc := 3\n",
+			new:    "\t\t\tc := 3\n\t\t\tz := 0\n",
+		},
+		{
+			name:   "insert before statement in case clause",
+			target: "e",
+			edit:   insertBefore,
+			old:    "\t\te := 5\n",
+			new:    "\t\tz := 0\n\t\te := 5\n",
+		},
+		{
+			name:   "replace keeps comments of target",
+			target: "d",
+			edit:   replace,
+			old:    "\t\td := 4\n",
+			new:    "\t\tz := 0\n",
+		},
+		{
+			name:   "replace statement in if block",
+			target: "b",
+			edit:   replace,
+			old:    "\t\tb := 2\n",
+			new:    "\t\tz := 0\n",
+		},
+		{
+			name:   "remove statement in nested for block",
+			target: "c",
+			edit:   remove,
+			old:    "\t\t\tc := 3\n",
+			new:    "",
+		},
+		{
+			name:   "remove statement in case clause",
+			target: "e",
+			edit:   remove,
+			old:    "\t\te := 5\n",
+			new:    "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := NewDst(strings.NewReader(statementsSource))
+			body := src.FindFunction("^f$").GetBody()
+			target := body.FindStatement(IsAssignmentTo(test.target))
+			statement, err := ParseStatement("z := 0", Placeholders{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.edit(body, target, statement) {
+				t.Fatal("target is not found")
+			}
+			expected := formatCode(t, strings.Replace(statementsSource, test.old, test.new, 1))
+			if actual := formatSource(t, src); actual != expected {
+				t.Errorf("unexpected output:\n%s", actual)
+			}
+		})
+	}
+}
+
+func TestFunctionBodyEditingMissingTarget(t *testing.T) {
+	src := NewDst(strings.NewReader(statementsSource))
+	body := src.FindFunction("^f$").GetBody()
+	var literalStatement dst.Stmt
+	dst.Inspect(src.Dst, func(node dst.Node) bool {
+		if literal, ok := node.(*dst.FuncLit); ok {
+			literalStatement = literal.Body.List[0]
+		}
+		return true
+	})
+	statement := NewReturnStatement()
+
+	if body.InsertBefore(literalStatement, statement) || body.InsertAfter(literalStatement, statement) ||
+		body.Replace(literalStatement, statement) || body.Remove(literalStatement) {
+		t.Error("statement of function literal is edited")
+	}
+	if actual := formatSource(t, src); actual != formatCode(t, statementsSource) {
+		t.Errorf("unexpected output:\n%s", actual)
+	}
+}